@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+const (
+	// commandExitExit stops streaming once the command exits.
+	commandExitExit = "exit"
+	// commandExitRestart starts the command again at the next segment.
+	commandExitRestart = "restart"
+	// commandExitContinue carries on with the other outputs, if any.
+	commandExitContinue = "continue"
+)
+
+// commandExitTimeout is how long a command that stopped reading its input is
+// given to exit before it is killed.
+const commandExitTimeout = time.Second * 5
+
+// errCommandExited is returned by writes to a command that exited, unless
+// it is restarted.
+var errCommandExited = errors.New("external command exited")
+
+// validCommandExit reports whether policy is a valid --command-exit value.
+func validCommandExit(policy string) bool {
+	switch policy {
+	case commandExitExit, commandExitRestart, commandExitContinue:
+		return true
+	}
+	return false
+}
+
+// process is a running instance of a command.
+type process struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	done  chan struct{}
+	// err is the result of waiting for the process, set once done is closed.
+	err error
+}
+
+// command writes the stream to the standard input of an external command,
+// handling the command exiting according to policy.
+type command struct {
+	name   string
+	args   []string
+	policy string
+	logger *log.Logger
+
+	// proc is the running process, or nil once it exited.
+	proc *process
+	// err is the result of waiting for the last process that exited.
+	err error
+}
+
+// startCommand starts name with args.
+func startCommand(name string, args []string, policy string, logger *log.Logger) (*command, error) {
+	c := &command{name: name, args: args, policy: policy, logger: logger}
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *command) start() error {
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("could not acquire external command input: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start external command: %w", err)
+	}
+
+	p := &process{cmd: cmd, stdin: stdin, done: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.done)
+	}()
+
+	c.proc = p
+	return nil
+}
+
+func (c *command) Write(p []byte) (int, error) {
+	if c.proc == nil {
+		if c.policy == commandExitRestart {
+			// The rest of the segment is dropped until the command is restarted.
+			return len(p), nil
+		}
+		return 0, errCommandExited
+	}
+
+	n, err := c.proc.stdin.Write(p)
+	if err != nil {
+		c.stop(nil, commandExitTimeout)
+		return c.exited(len(p))
+	}
+	return n, nil
+}
+
+// BeginSegment implements twitch.SegmentWriter. It notices a command that
+// exited since the last segment, and restarts it if that is the policy,
+// asking for the initialization section so the new process can play the stream.
+func (c *command) BeginSegment(segment twitch.Segment) (bool, error) {
+	if c.proc != nil {
+		select {
+		case <-c.proc.done:
+			c.stop(nil, 0)
+			if _, err := c.exited(0); err != nil {
+				return false, err
+			}
+		default:
+		}
+	}
+
+	if c.proc == nil && c.policy == commandExitRestart {
+		if err := c.start(); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// EndSegment implements twitch.SegmentWriter.
+func (c *command) EndSegment(segment twitch.Segment) error {
+	return nil
+}
+
+// exited reports that the command exited, returning the result of a write of
+// n bytes that found out.
+func (c *command) exited(n int) (int, error) {
+	msg := errCommandExited.Error()
+	if c.err != nil {
+		msg += ": " + c.err.Error()
+	}
+
+	if c.policy == commandExitRestart {
+		c.logger.Printf("%s, restarting at the next segment\n", msg)
+		return n, nil
+	}
+
+	c.logger.Printf("%s\n", msg)
+	return 0, errCommandExited
+}
+
+// stop closes the input of the running process, sends it sig if set, and
+// waits for it to exit. If timeout is non-zero, the process is killed if it
+// does not exit in time.
+func (c *command) stop(sig os.Signal, timeout time.Duration) {
+	p := c.proc
+	c.proc = nil
+
+	p.stdin.Close()
+	if sig != nil {
+		// Not supported on every platform, in which case the closed
+		// input is left to end the command.
+		p.cmd.Process.Signal(sig)
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	select {
+	case <-p.done:
+	case <-expired:
+		p.cmd.Process.Kill()
+		<-p.done
+	}
+
+	c.err = p.err
+}
+
+// Close stops the command, sending it sig if set, and returns the result
+// of waiting for it. Without sig, the command is left to finish reading
+// its input.
+func (c *command) Close(sig os.Signal) error {
+	if c.proc == nil {
+		return nil
+	}
+	c.stop(sig, 0)
+	return c.err
+}
+
+// exitCode returns the exit status of the last process that exited.
+func (c *command) exitCode() int {
+	var exitErr *exec.ExitError
+	switch {
+	case c.err == nil:
+		return 0
+	case errors.As(c.err, &exitErr) && exitErr.ExitCode() >= 0:
+		return exitErr.ExitCode()
+	}
+	return 1
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os/exec"
+	"testing"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+func TestCommandExit(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	logger := log.New(io.Discard, "", 0)
+
+	exited := func(policy string) *command {
+		c, err := startCommand("sh", []string{"-c", "exit 3"}, policy, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-c.proc.done
+		return c
+	}
+
+	c := exited(commandExitExit)
+	if _, err := c.BeginSegment(twitch.Segment{}); !errors.Is(err, errCommandExited) {
+		t.Errorf("BeginSegment after exit returned %v, expected %v", err, errCommandExited)
+	}
+	if code := c.exitCode(); code != 3 {
+		t.Errorf("exit code is %d, expected 3", code)
+	}
+
+	c = exited(commandExitContinue)
+	if _, err := c.Write([]byte("data")); !errors.Is(err, errCommandExited) {
+		t.Errorf("Write after exit returned %v, expected %v", err, errCommandExited)
+	}
+
+	c = exited(commandExitRestart)
+	if n, err := c.Write([]byte("data")); n != 4 || err != nil {
+		t.Errorf("Write after exit returned %d, %v, expected the data to be dropped", n, err)
+	}
+	reinit, err := c.BeginSegment(twitch.Segment{})
+	if err != nil || !reinit || c.proc == nil {
+		t.Errorf("BeginSegment after exit returned %v, %v, expected a restart", reinit, err)
+	}
+	if err := c.Close(nil); err == nil || c.exitCode() != 3 {
+		t.Errorf("restarted command exited with %v, expected exit status 3", err)
+	}
+}
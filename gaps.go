@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+// gapReport summarises the segments missed during a recording.
+type gapReport struct {
+	Channel        string       `json:"channel"`
+	Start          time.Time    `json:"start"`
+	End            time.Time    `json:"end"`
+	Complete       bool         `json:"complete"`
+	MissedSegments int          `json:"missed_segments"`
+	MissedDuration float64      `json:"missed_duration"`
+	Gaps           []twitch.Gap `json:"gaps"`
+}
+
+func newGapReport(channel string) *gapReport {
+	return &gapReport{
+		Channel: channel,
+		Start:   time.Now(),
+		Gaps:    []twitch.Gap{},
+	}
+}
+
+func (r *gapReport) add(gap twitch.Gap) {
+	r.Gaps = append(r.Gaps, gap)
+	r.MissedSegments += gap.Count()
+	r.MissedDuration += gap.Duration
+}
+
+// write writes the report as JSON to name.
+func (r *gapReport) write(name string) error {
+	r.End = time.Now()
+	r.Complete = len(r.Gaps) == 0
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
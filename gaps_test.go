@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+func TestGapReport(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "gaps.json")
+
+	read := func() gapReport {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r gapReport
+		if err := json.Unmarshal(b, &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	r := newGapReport("channel")
+	if err := r.write(name); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); !got.Complete || got.MissedSegments != 0 || got.Gaps == nil {
+		t.Errorf("report without gaps is %+v, expected it to be complete with an empty list of gaps", got)
+	}
+
+	r.add(twitch.Gap{FirstSeq: 10, LastSeq: 12, Duration: 6})
+	r.add(twitch.Gap{FirstSeq: 20, LastSeq: 20, Duration: 2.5})
+	if err := r.write(name); err != nil {
+		t.Fatal(err)
+	}
+
+	got := read()
+	if got.Complete {
+		t.Error("report with gaps is complete")
+	}
+	if got.MissedSegments != 4 || got.MissedDuration != 8.5 || len(got.Gaps) != 2 {
+		t.Errorf("report missed %d segment(s) (%vs) in %d gap(s), expected 4 (8.5s) in 2", got.MissedSegments, got.MissedDuration, len(got.Gaps))
+	}
+	if got.Channel != "channel" || got.End.Before(got.Start) {
+		t.Errorf("report is for %q from %v to %v", got.Channel, got.Start, got.End)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/Hakkin/twitchpipe
 go 1.18
 
 require (
+	golang.org/x/net v0.4.0
 	golang.org/x/term v0.3.0
 	rsc.io/getopt v0.0.0-20170811000552-20be20937449
 )
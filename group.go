@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+// selection is a parsed --group expression: a comma-separated list of
+// alternatives tried in order, and constraints every candidate must meet.
+// Alternatives are "best", "worst", a resolution such as "720p" or "720p60",
+// or an exact group such as "audio_only". Constraints compare a property of
+// the playlist with a value, e.g. "height<=720".
+type selection struct {
+	alternatives []string
+	constraints  []constraint
+}
+
+// constraint is a comparison such as "fps>=60".
+type constraint struct {
+	key string
+	op  string
+	// value is the number compared with, or the codec for "codec".
+	value float64
+	codec string
+}
+
+var (
+	constraintRegex = regexp.MustCompile(`^([a-z_]+)\s*(<=|>=|!=|=|<|>)\s*(.+)$`)
+	resolutionRegex = regexp.MustCompile(`^([0-9]+)p([0-9]+)?$`)
+)
+
+// normalizeCodec returns the name of the video codec name as used by the
+// twitch package, or false if it is not supported.
+func normalizeCodec(name string) (string, bool) {
+	switch name = strings.ToLower(strings.TrimSpace(name)); name {
+	case "h264", "avc":
+		return "h264", true
+	case "h265", "hevc":
+		return "h265", true
+	case "av1":
+		return "av1", true
+	}
+	return "", false
+}
+
+// parseSelection parses the --group expression s.
+func parseSelection(s string) (selection, error) {
+	var sel selection
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return selection{}, fmt.Errorf("empty group in %q", s)
+		}
+
+		if !strings.ContainsAny(item, "<>=!") {
+			sel.alternatives = append(sel.alternatives, item)
+			continue
+		}
+
+		c, err := parseConstraint(item)
+		if err != nil {
+			return selection{}, err
+		}
+		sel.constraints = append(sel.constraints, c)
+	}
+
+	if len(sel.alternatives) == 0 {
+		sel.alternatives = []string{"best"}
+	}
+
+	return sel, nil
+}
+
+func parseConstraint(s string) (constraint, error) {
+	m := constraintRegex.FindStringSubmatch(s)
+	if m == nil {
+		return constraint{}, fmt.Errorf("invalid constraint %q", s)
+	}
+	c := constraint{key: m[1], op: m[2]}
+
+	switch c.key {
+	case "codec":
+		if c.op != "=" && c.op != "!=" {
+			return constraint{}, fmt.Errorf("invalid constraint %q, codecs can only be compared with = or !=", s)
+		}
+		codec, ok := normalizeCodec(m[3])
+		if !ok {
+			return constraint{}, fmt.Errorf("unknown codec %q, expected h264, h265 or av1", m[3])
+		}
+		c.codec = codec
+	case "height", "width", "fps", "bitrate":
+		num, multiplier := strings.ToLower(m[3]), 1.0
+		if c.key == "bitrate" {
+			// Matching the bitrates shown by --list-groups.
+			if strings.HasSuffix(num, "k") {
+				num, multiplier = strings.TrimSuffix(num, "k"), 1024
+			} else if strings.HasSuffix(num, "m") {
+				num, multiplier = strings.TrimSuffix(num, "m"), 1024*1024
+			}
+		}
+		v, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return constraint{}, fmt.Errorf("invalid value in constraint %q", s)
+		}
+		c.value = v * multiplier
+	default:
+		return constraint{}, fmt.Errorf("unknown property %q in constraint %q, expected height, width, fps, bitrate or codec", c.key, s)
+	}
+
+	return c, nil
+}
+
+// frameRate returns the frame rate of p, falling back to the one in its
+// group name, e.g. 60 for "720p60".
+func frameRate(p twitch.PlaylistInfo) float64 {
+	if p.FrameRate > 0 {
+		return p.FrameRate
+	}
+	if m := resolutionRegex.FindStringSubmatch(p.Group); m != nil && m[2] != "" {
+		fps, _ := strconv.ParseFloat(m[2], 64)
+		return fps
+	}
+	return 0
+}
+
+// match reports whether p meets c.
+func (c constraint) match(p twitch.PlaylistInfo) bool {
+	if c.key == "codec" {
+		return (p.VideoCodec() == c.codec) == (c.op == "=")
+	}
+
+	var v float64
+	switch c.key {
+	case "height":
+		v = float64(p.Height)
+	case "width":
+		v = float64(p.Width)
+	case "fps":
+		v = frameRate(p)
+	case "bitrate":
+		v = float64(p.Bandwidth)
+	}
+
+	switch c.op {
+	case "<":
+		return v < c.value
+	case "<=":
+		return v <= c.value
+	case ">":
+		return v > c.value
+	case ">=":
+		return v >= c.value
+	case "!=":
+		return v != c.value
+	}
+	return v == c.value
+}
+
+// isAudioOnly reports whether p has no video.
+func isAudioOnly(p twitch.PlaylistInfo) bool {
+	return p.Group == "audio_only" || (p.Height == 0 && p.Width == 0 && !strings.Contains(p.Codec, ","))
+}
+
+// decodable reports whether the video codec of p, if known, is one of --codecs.
+func decodable(p twitch.PlaylistInfo) bool {
+	codec := p.VideoCodec()
+	if codec == "" {
+		return true
+	}
+	for _, c := range codecs {
+		if c == codec {
+			return true
+		}
+	}
+	return false
+}
+
+// bestOf returns the best of playlists, preferring the codecs of --codecs in
+// order. Playlists of unknown codecs are only chosen if there are no others.
+func bestOf(playlists []twitch.PlaylistInfo) (twitch.PlaylistInfo, bool) {
+	best := twitch.FindPreferred(playlists, codecs)
+	if best.URL == "" {
+		best = twitch.FindBest(playlists)
+	}
+	return best, best.URL != ""
+}
+
+// choose returns the playlist picked by the first alternative that matches
+// any of the playlists meeting the constraints of s. Playlists that cannot be
+// decoded with --codecs are never chosen.
+func (s selection) choose(playlists []twitch.PlaylistInfo) (twitch.PlaylistInfo, bool) {
+	var candidates []twitch.PlaylistInfo
+outer:
+	for _, p := range playlists {
+		if !decodable(p) {
+			continue
+		}
+		for _, c := range s.constraints {
+			if !c.match(p) {
+				continue outer
+			}
+		}
+		candidates = append(candidates, p)
+	}
+
+	for _, alt := range s.alternatives {
+		if p, found := chooseAlternative(candidates, alt); found {
+			return p, true
+		}
+	}
+
+	return twitch.PlaylistInfo{}, false
+}
+
+// chooseAlternative returns the playlist picked by the alternative alt.
+func chooseAlternative(playlists []twitch.PlaylistInfo, alt string) (twitch.PlaylistInfo, bool) {
+	// Exact groups come first, so that groups named like the keywords
+	// below can still be selected. Variants sharing a group are chosen
+	// between by --codecs.
+	var exact []twitch.PlaylistInfo
+	for _, p := range playlists {
+		if p.Group == alt {
+			exact = append(exact, p)
+		}
+	}
+	if len(exact) > 0 {
+		return bestOf(exact)
+	}
+
+	switch alt {
+	case "best":
+		return bestOf(playlists)
+	case "worst":
+		var worst twitch.PlaylistInfo
+		var found bool
+		for _, p := range playlists {
+			if isAudioOnly(p) {
+				continue
+			}
+			if !found || p.Bandwidth < worst.Bandwidth {
+				worst, found = p, true
+			}
+		}
+		return worst, found
+	}
+
+	// A resolution matches the best variant of that height, and the frame
+	// rate if given.
+	m := resolutionRegex.FindStringSubmatch(alt)
+	if m == nil {
+		return twitch.PlaylistInfo{}, false
+	}
+	height, _ := strconv.Atoi(m[1])
+	fps, _ := strconv.ParseFloat(m[2], 64)
+
+	var matches []twitch.PlaylistInfo
+	for _, p := range playlists {
+		if p.Height == height && (fps == 0 || int(frameRate(p)+0.5) == int(fps)) {
+			matches = append(matches, p)
+		}
+	}
+
+	return bestOf(matches)
+}
+
+// selectPlaylist returns the playlist chosen by the --group expression group.
+// Invalid expressions select nothing.
+func selectPlaylist(playlists []twitch.PlaylistInfo, group string) (twitch.PlaylistInfo, bool) {
+	sel, err := parseSelection(group)
+	if err != nil {
+		return twitch.PlaylistInfo{}, false
+	}
+	return sel.choose(playlists)
+}
+
+// sameVariant returns the playlist among playlists that matches selected in
+// group, video codec, resolution and frame rate. It is used to pick the same
+// variant again after the playlists are refreshed, as variants encoded with
+// different codecs can share a group.
+func sameVariant(playlists []twitch.PlaylistInfo, selected twitch.PlaylistInfo) (twitch.PlaylistInfo, bool) {
+	for _, p := range playlists {
+		if p.Group == selected.Group && p.VideoCodec() == selected.VideoCodec() &&
+			p.Width == selected.Width && p.Height == selected.Height && frameRate(p) == frameRate(selected) {
+			return p, true
+		}
+	}
+	return twitch.PlaylistInfo{}, false
+}
+
+const (
+	listFormatTable = "table"
+	listFormatJSON  = "json"
+	listFormatCSV   = "csv"
+)
+
+// validListFormat reports whether format is a valid --format value.
+func validListFormat(format string) bool {
+	switch format {
+	case listFormatTable, listFormatJSON, listFormatCSV:
+		return true
+	}
+	return false
+}
+
+// groupListing is a playlist as written by --list-groups in the JSON format.
+type groupListing struct {
+	Group      string  `json:"group"`
+	Name       string  `json:"name"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	FrameRate  float64 `json:"frame_rate"`
+	Codecs     string  `json:"codecs"`
+	VideoCodec string  `json:"video_codec"`
+	Bandwidth  int     `json:"bandwidth"`
+	URL        string  `json:"url,omitempty"`
+	// Best is set for the playlist "best" selects.
+	Best bool `json:"best"`
+}
+
+// writeGroups writes playlists to w in format, which is JSON or CSV.
+// URLs are only included if withURLs is set.
+func writeGroups(w io.Writer, playlists []twitch.PlaylistInfo, format string, withURLs bool) error {
+	best, found := selectPlaylist(playlists, "best")
+
+	listings := make([]groupListing, 0, len(playlists))
+	for _, p := range playlists {
+		l := groupListing{
+			Group:      p.Group,
+			Name:       p.Name,
+			Width:      p.Width,
+			Height:     p.Height,
+			FrameRate:  p.FrameRate,
+			Codecs:     p.Codec,
+			VideoCodec: p.VideoCodec(),
+			Bandwidth:  p.Bandwidth,
+			Best:       found && p.URL == best.URL,
+		}
+		if withURLs {
+			l.URL = p.URL
+		}
+		listings = append(listings, l)
+	}
+
+	if format == listFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(listings)
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"group", "name", "width", "height", "frame_rate", "codecs", "video_codec", "bandwidth", "best"}
+	if withURLs {
+		header = append(header, "url")
+	}
+	cw.Write(header)
+
+	for _, l := range listings {
+		record := []string{
+			l.Group,
+			l.Name,
+			strconv.Itoa(l.Width),
+			strconv.Itoa(l.Height),
+			strconv.FormatFloat(l.FrameRate, 'f', -1, 64),
+			l.Codecs,
+			l.VideoCodec,
+			strconv.Itoa(l.Bandwidth),
+			strconv.FormatBool(l.Best),
+		}
+		if withURLs {
+			record = append(record, l.URL)
+		}
+		cw.Write(record)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+func TestSelectPlaylist(t *testing.T) {
+	playlists := []twitch.PlaylistInfo{
+		{Group: "chunked", Height: 1080, Bandwidth: 6000 << 10, Codec: "avc1.64002A,mp4a.40.2", FrameRate: 60, URL: "source"},
+		{Group: "1080p60", Height: 1080, Bandwidth: 5000 << 10, Codec: "hvc1.2.4.L123.B0,mp4a.40.2", FrameRate: 60, URL: "hevc"},
+		{Group: "720p30", Height: 720, Bandwidth: 2500 << 10, Codec: "avc1.4D401F,mp4a.40.2", FrameRate: 30, URL: "720p30"},
+		{Group: "480p30", Height: 480, Bandwidth: 1400 << 10, Codec: "avc1.4D401F,mp4a.40.2", URL: "480p30"},
+		{Group: "audio_only", Bandwidth: 160 << 10, Codec: "mp4a.40.2", URL: "audio"},
+	}
+
+	tests := []struct {
+		group string
+		exp   string
+	}{
+		{"best", "source"},
+		{"worst", "480p30"},
+		{"audio_only", "audio"},
+		{"720p60,720p,480p,best", "720p30"},
+		{"720p60,480p30", "480p30"},
+		{"1080p60", "hevc"},
+		{"1080p", "source"},
+		{"height<=720", "720p30"},
+		{"fps>=60,worst", "hevc"},
+		{"fps<60,best", "720p30"},
+		{"codec=h265", "hevc"},
+		{"codec!=h264,worst", "hevc"},
+		{"bitrate<2000k", "480p30"},
+		{"height>1080", ""},
+		{"360p", ""},
+	}
+
+	for _, test := range tests {
+		p, found := selectPlaylist(playlists, test.group)
+		if found != (test.exp != "") || p.URL != test.exp {
+			t.Errorf("selectPlaylist(%q) = %q, %v, expected %q", test.group, p.URL, found, test.exp)
+		}
+	}
+}
+
+func TestSelectPlaylistCodecs(t *testing.T) {
+	defer func(c codecList) { codecs = c }(codecs)
+
+	playlists := []twitch.PlaylistInfo{
+		{Group: "chunked", Height: 1440, FrameRate: 60, Bandwidth: 8000 << 10, Codec: "hvc1.2.4.L123.B0,mp4a.40.2", URL: "source"},
+		{Group: "1080p60", Height: 1080, FrameRate: 60, Bandwidth: 6000 << 10, Codec: "avc1.64002A,mp4a.40.2", URL: "h264"},
+		{Group: "1080p60_av1", Height: 1080, FrameRate: 60, Bandwidth: 4000 << 10, Codec: "av01.0.08M.08,mp4a.40.2", URL: "av1"},
+	}
+
+	tests := []struct {
+		codecs string
+		group  string
+		exp    string
+	}{
+		{"av1,h265,h264", "best", "source"},
+		{"h264", "best", "h264"},
+		{"h264", "chunked", ""},
+		{"av1,h264", "best", "av1"},
+		{"h264,av1", "1080p", "h264"},
+	}
+
+	for _, test := range tests {
+		if err := codecs.Set(test.codecs); err != nil {
+			t.Fatal(err)
+		}
+		p, found := selectPlaylist(playlists, test.group)
+		if found != (test.exp != "") || p.URL != test.exp {
+			t.Errorf("selectPlaylist(%q) with codecs %q = %q, %v, expected %q", test.group, test.codecs, p.URL, found, test.exp)
+		}
+	}
+
+	// Variants sharing a group are chosen between by codec preference.
+	shared := []twitch.PlaylistInfo{
+		{Group: "chunked", Height: 1080, FrameRate: 60, Bandwidth: 6000 << 10, Codec: "avc1.64002A,mp4a.40.2", URL: "h264"},
+		{Group: "chunked", Height: 1080, FrameRate: 60, Bandwidth: 4000 << 10, Codec: "av01.0.08M.08,mp4a.40.2", URL: "av1"},
+	}
+	for _, test := range []struct{ codecs, exp string }{{"av1,h264", "av1"}, {"h264,av1", "h264"}} {
+		if err := codecs.Set(test.codecs); err != nil {
+			t.Fatal(err)
+		}
+		if p, _ := selectPlaylist(shared, "chunked"); p.URL != test.exp {
+			t.Errorf("selectPlaylist(\"chunked\") with codecs %q = %q, expected %q", test.codecs, p.URL, test.exp)
+		}
+	}
+
+	if err := codecs.Set("h264,vp9"); err == nil {
+		t.Error("unknown codec was accepted")
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	for _, s := range []string{"", "best,", "height=>720", "depth<3", "codec<h264", "codec=vp9", "fps>=sixty"} {
+		if _, err := parseSelection(s); err == nil {
+			t.Errorf("parseSelection(%q) did not return an error", s)
+		}
+	}
+}
+
+func TestWriteGroups(t *testing.T) {
+	playlists := []twitch.PlaylistInfo{
+		{Group: "chunked", Name: "1080p60 (source)", Width: 1920, Height: 1080, FrameRate: 60, Bandwidth: 6000000, Codec: "avc1.64002A,mp4a.40.2", URL: "https://example.invalid/source.m3u8"},
+		{Group: "audio_only", Name: "audio_only", Bandwidth: 160000, Codec: "mp4a.40.2", URL: "https://example.invalid/audio.m3u8"},
+	}
+
+	var b strings.Builder
+	if err := writeGroups(&b, playlists, listFormatCSV, false); err != nil {
+		t.Fatal(err)
+	}
+	exp := "group,name,width,height,frame_rate,codecs,video_codec,bandwidth,best\n" +
+		"chunked,1080p60 (source),1920,1080,60,\"avc1.64002A,mp4a.40.2\",h264,6000000,true\n" +
+		"audio_only,audio_only,0,0,0,mp4a.40.2,,160000,false\n"
+	if b.String() != exp {
+		t.Errorf("CSV is\n%s\nexpected\n%s", b.String(), exp)
+	}
+
+	b.Reset()
+	if err := writeGroups(&b, playlists, listFormatJSON, true); err != nil {
+		t.Fatal(err)
+	}
+	var listings []groupListing
+	if err := json.Unmarshal([]byte(b.String()), &listings); err != nil {
+		t.Fatal(err)
+	}
+	expListings := []groupListing{
+		{Group: "chunked", Name: "1080p60 (source)", Width: 1920, Height: 1080, FrameRate: 60, Codecs: "avc1.64002A,mp4a.40.2", VideoCodec: "h264", Bandwidth: 6000000, URL: "https://example.invalid/source.m3u8", Best: true},
+		{Group: "audio_only", Name: "audio_only", Codecs: "mp4a.40.2", Bandwidth: 160000, URL: "https://example.invalid/audio.m3u8"},
+	}
+	if !reflect.DeepEqual(listings, expListings) {
+		t.Errorf("JSON is %+v, expected %+v", listings, expListings)
+	}
+}
+
+func TestPrintGroupsBest(t *testing.T) {
+	playlists := []twitch.PlaylistInfo{
+		{Group: "chunked", Name: "1080p60 (source)", Height: 1080, Bandwidth: 6000000, Codec: "avc1.64002A,mp4a.40.2", URL: "https://example.invalid/h264.m3u8"},
+		{Group: "chunked", Name: "1080p60 (source)", Height: 1080, Bandwidth: 5000000, Codec: "av01.0.08M.08,mp4a.40.2", URL: "https://example.invalid/av1.m3u8"},
+	}
+
+	var b strings.Builder
+	printGroups(&b, playlists)
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, expected 3:\n%s", len(lines), b.String())
+	}
+	if strings.HasSuffix(lines[1], "(best)") {
+		t.Errorf("variant sharing the best group is marked best: %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "(best)") {
+		t.Errorf("best variant is not marked best: %q", lines[2])
+	}
+}
+
+func TestSameVariant(t *testing.T) {
+	playlists := []twitch.PlaylistInfo{
+		{Group: "chunked", Width: 1920, Height: 1080, FrameRate: 60, Codec: "avc1.64002A,mp4a.40.2", URL: "https://example.invalid/h264.m3u8"},
+		{Group: "chunked", Width: 1920, Height: 1080, FrameRate: 60, Codec: "av01.0.08M.08,mp4a.40.2", URL: "https://example.invalid/av1.m3u8"},
+		{Group: "720p60", Width: 1280, Height: 720, FrameRate: 60, Codec: "avc1.4D401F,mp4a.40.2", URL: "https://example.invalid/720p60.m3u8"},
+	}
+
+	selected, found := selectPlaylist(playlists, "best")
+	if !found || selected.URL != "https://example.invalid/av1.m3u8" {
+		t.Fatalf("best selected %q, expected the av1 variant", selected.URL)
+	}
+
+	// The refreshed playlists have new URLs, as they carry a new token.
+	refreshed := make([]twitch.PlaylistInfo, len(playlists))
+	for i, p := range playlists {
+		p.URL += "?token=2"
+		refreshed[i] = p
+	}
+
+	p, found := sameVariant(refreshed, selected)
+	if !found || p.URL != "https://example.invalid/av1.m3u8?token=2" {
+		t.Errorf("refresh selected %q, expected the av1 variant", p.URL)
+	}
+
+	if _, found := sameVariant(refreshed[:1], selected); found {
+		t.Error("refresh selected a variant with a different codec")
+	}
+}
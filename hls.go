@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+// hlsPlaylistName is the name of the media playlist in the HLS directory.
+const hlsPlaylistName = "index.m3u8"
+
+// hlsEntry is a segment listed in the local media playlist.
+type hlsEntry struct {
+	name     string
+	title    string
+	duration float64
+	// init is the name of the initialization section of the segment, if any.
+	init          string
+	discontinuity bool
+}
+
+// hlsWriter republishes the stream as a live HLS media playlist in a
+// directory, keeping the last window segments. Sequence numbers are counted
+// locally, so segments skipped upstream appear as discontinuities.
+type hlsWriter struct {
+	dir    string
+	window int
+
+	entries []hlsEntry
+	// seq is the media sequence number of the next segment.
+	seq int
+	// discontinuitySeq is the discontinuity sequence number of entries[0].
+	discontinuitySeq int
+	targetDuration   int
+
+	// file is the segment being written, or nil.
+	file    *os.File
+	segment twitch.Segment
+	// lastSeq is the upstream sequence number of the last segment written.
+	lastSeq int
+	written bool
+
+	// init is the name of the last initialization section written.
+	init        string
+	initFile    *os.File
+	initCount   int
+	initWritten bool
+}
+
+// newHLSWriter creates dir, if necessary, for a playlist of window segments.
+func newHLSWriter(dir string, window int) (*hlsWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &hlsWriter{dir: dir, window: window}, nil
+}
+
+// extension returns the file extension of the path of uri, or def if it has none.
+func extension(uri, def string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return def
+	}
+	if ext := path.Ext(u.Path); ext != "" {
+		return ext
+	}
+	return def
+}
+
+// BeginSegment implements twitch.SegmentWriter, creating the segment's file.
+// A segment that was begun but never ended is discarded.
+func (h *hlsWriter) BeginSegment(segment twitch.Segment) (bool, error) {
+	h.abandon()
+
+	name := fmt.Sprintf("%d%s", h.seq, extension(segment.URI, ".ts"))
+	f, err := os.Create(filepath.Join(h.dir, name))
+	if err != nil {
+		return false, err
+	}
+
+	h.file, h.segment = f, segment
+	h.initWritten = false
+	return false, nil
+}
+
+// WriteInit implements twitch.InitWriter, writing the initialization section
+// to its own file.
+func (h *hlsWriter) WriteInit(p []byte) (int, error) {
+	if !h.initWritten {
+		if h.initFile != nil {
+			h.initFile.Close()
+		}
+
+		name := fmt.Sprintf("init%d%s", h.initCount, extension(h.segment.MapURI, ".mp4"))
+		f, err := os.Create(filepath.Join(h.dir, name))
+		if err != nil {
+			return 0, err
+		}
+
+		h.initCount++
+		h.init, h.initFile = name, f
+		h.initWritten = true
+	}
+
+	return h.initFile.Write(p)
+}
+
+func (h *hlsWriter) Write(p []byte) (int, error) {
+	if h.file == nil {
+		return 0, os.ErrClosed
+	}
+	return h.file.Write(p)
+}
+
+// EndSegment implements twitch.SegmentWriter, adding segment to the playlist.
+func (h *hlsWriter) EndSegment(segment twitch.Segment) error {
+	if h.initWritten {
+		err := h.initFile.Close()
+		h.initFile = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	name := filepath.Base(h.file.Name())
+	err := h.file.Close()
+	h.file = nil
+	if err != nil {
+		return err
+	}
+
+	entry := hlsEntry{
+		name:     name,
+		title:    segment.Name,
+		duration: segment.Duration,
+		// Segments missed or skipped upstream break the timeline too.
+		discontinuity: h.written && (segment.Discontinuity || segment.Seq != h.lastSeq+1),
+	}
+	if segment.MapURI != "" {
+		entry.init = h.init
+	}
+
+	h.entries = append(h.entries, entry)
+	h.seq++
+	h.lastSeq, h.written = segment.Seq, true
+	if d := int(math.Ceil(segment.Duration)); d > h.targetDuration {
+		h.targetDuration = d
+	}
+
+	h.trim()
+	return h.writePlaylist(false)
+}
+
+// abandon discards the segment being written, if any.
+func (h *hlsWriter) abandon() {
+	if h.file != nil {
+		h.file.Close()
+		os.Remove(h.file.Name())
+		h.file = nil
+	}
+}
+
+// trim removes the entries that fell out of the window, along with their
+// files and initialization sections that are no longer used.
+func (h *hlsWriter) trim() {
+	for len(h.entries) > h.window {
+		old := h.entries[0]
+		h.entries = h.entries[1:]
+
+		os.Remove(filepath.Join(h.dir, old.name))
+		if h.entries[0].discontinuity {
+			h.discontinuitySeq++
+		}
+
+		if old.init != "" && old.init != h.entries[0].init {
+			os.Remove(filepath.Join(h.dir, old.init))
+		}
+	}
+}
+
+// writePlaylist replaces the media playlist, ending it if end is set.
+func (h *hlsWriter) writePlaylist(end bool) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:6\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", h.targetDuration)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", h.seq-len(h.entries))
+	fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", h.discontinuitySeq)
+
+	var init string
+	for i, e := range h.entries {
+		if e.discontinuity && i > 0 {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if e.init != "" && e.init != init {
+			fmt.Fprintf(&b, "#EXT-X-MAP:URI=%q\n", e.init)
+			init = e.init
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,%s\n", e.duration, e.title)
+		b.WriteString(e.name + "\n")
+	}
+
+	if end {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	name := filepath.Join(h.dir, hlsPlaylistName)
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// Close ends the playlist.
+func (h *hlsWriter) Close() error {
+	h.abandon()
+	if h.initFile != nil {
+		h.initFile.Close()
+		h.initFile = nil
+	}
+	return h.writePlaylist(true)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+func TestHLSWriter(t *testing.T) {
+	dir := t.TempDir()
+	h, err := newHLSWriter(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(segment twitch.Segment, init bool, end bool) {
+		if _, err := h.BeginSegment(segment); err != nil {
+			t.Fatal(err)
+		}
+		if init {
+			if _, err := h.WriteInit([]byte("init")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := h.Write([]byte("data")); err != nil {
+			t.Fatal(err)
+		}
+		if end {
+			if err := h.EndSegment(segment); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	write(twitch.Segment{Seq: 10, URI: "https://example.com/a.mp4", MapURI: "https://example.com/init.mp4", Duration: 2, Name: "live"}, true, true)
+	write(twitch.Segment{Seq: 11, URI: "https://example.com/b.mp4", MapURI: "https://example.com/init.mp4", Duration: 2, Name: "live"}, false, true)
+	// Interrupted, must be discarded.
+	write(twitch.Segment{Seq: 12, URI: "https://example.com/c.mp4", MapURI: "https://example.com/init.mp4", Duration: 2}, false, false)
+	// Segment 12 is missing, so this starts a new discontinuity.
+	write(twitch.Segment{Seq: 13, URI: "https://example.com/d.mp4", MapURI: "https://example.com/init.mp4", Duration: 2.5, Name: "live"}, false, true)
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, hlsPlaylistName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := strings.Join([]string{
+		"#EXTM3U",
+		"#EXT-X-VERSION:6",
+		"#EXT-X-TARGETDURATION:3",
+		"#EXT-X-MEDIA-SEQUENCE:1",
+		"#EXT-X-DISCONTINUITY-SEQUENCE:0",
+		`#EXT-X-MAP:URI="init0.mp4"`,
+		"#EXTINF:2.000,live",
+		"1.mp4",
+		"#EXT-X-DISCONTINUITY",
+		"#EXTINF:2.500,live",
+		"2.mp4",
+		"#EXT-X-ENDLIST",
+		"",
+	}, "\n")
+	if string(data) != exp {
+		t.Errorf("playlist is\n%s\nexpected\n%s", data, exp)
+	}
+
+	for _, name := range []string{"init0.mp4", "1.mp4", "2.mp4"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s is missing: %v", name, err)
+		}
+	}
+	// 0.mp4 fell out of the window.
+	if _, err := os.Stat(filepath.Join(dir, "0.mp4")); !os.IsNotExist(err) {
+		t.Errorf("0.mp4 was not removed")
+	}
+}
@@ -1,23 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
-	"net/url"
 	"os"
-	"os/exec"
-	"strings"
+	"path/filepath"
 	"time"
 
 	"golang.org/x/term"
 	"rsc.io/getopt"
+
+	"github.com/Hakkin/twitchpipe/twitch"
 )
 
 var stdErr = log.New(os.Stderr, "", 0)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "record" {
+		os.Exit(record(os.Args[2:]))
+	}
+
 	getopt.Parse()
 
 	if showVersion {
@@ -34,162 +42,401 @@ func main() {
 		hideWindow()
 	}
 
-	if accessTokenDeviceID.string == nil {
-		accessTokenDeviceID.Set(randDeviceID())
-	}
-
 	externalCommand, externalArgs := len(flag.Args()) > 1, len(flag.Args()) > 2
 
-	username := flag.Arg(0)
-	if usernameURL {
-		u, err := url.Parse(username)
-		if err != nil {
-			stdErr.Fatalf("could not parse username as URL: %v\n", err)
-		}
+	target, err := parseTarget(flag.Arg(0), usernameURL, videoMode)
+	if err != nil {
+		stdErr.Printf("%v\n", err)
+		printUsage()
+		os.Exit(1)
+	}
 
-		path := u.Path
-		path = strings.TrimPrefix(path, "/")
+	if _, err := parseSelection(groupSelect); err != nil {
+		stdErr.Printf("invalid value for option '--group': %v\n", err)
+		os.Exit(1)
+	}
 
-		username = strings.Split(path, "/")[0]
+	if !validListFormat(listFormat) {
+		stdErr.Printf("invalid value %q for option '--format'\n", listFormat)
+		os.Exit(1)
 	}
 
-	if username == "" {
-		printUsage()
+	if outputTemplate.string == nil && splitting() {
+		stdErr.Println("options '--split-duration' and '--split-size' require '--output'")
 		os.Exit(1)
 	}
 
-	username = strings.ToLower(username)
+	if outputTemplate.string != nil {
+		if _, err := expandOutput(*outputTemplate.string, outputFields{}); err != nil {
+			stdErr.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	if term.IsTerminal(int(os.Stdout.Fd())) && !forceOutput && !externalCommand && !groupList {
-		stdErr.Println("[WARNING] You have not piped the output anywhere.")
-		stdErr.Println("          Outputting binary data to a terminal can be dangerous.")
-		stdErr.Println("          To bypass this safety feature, use the '--force-output' option.")
+	if hlsDir.string != nil {
+		if target.kind == clipTarget {
+			stdErr.Println("option '--hls' cannot be used with clips")
+			os.Exit(1)
+		}
+		if hlsWindow < 1 {
+			stdErr.Println("option '--hls-window' must be at least 1")
+			os.Exit(1)
+		}
+	}
+
+	if !validOutputExists(outputExists) {
+		stdErr.Printf("invalid value %q for option '--output-exists'\n", outputExists)
 		os.Exit(1)
 	}
 
-	client := &http.Client{
-		Timeout: time.Second * 10,
+	if !validCommandExit(commandExit) {
+		stdErr.Printf("invalid value %q for option '--command-exit'\n", commandExit)
+		os.Exit(1)
 	}
 
-	variables := map[string]any{
-		"platform":   accessTokenPlatform,
-		"playerType": accessTokenPlayerType,
+	if stdoutMode && externalCommand {
+		stdErr.Println("option '--stdout' cannot be used together with COMMAND, whose output goes to standard output")
+		os.Exit(1)
 	}
-	if accessTokenPlayerBackend.string != nil {
-		variables["playerBackend"] = *accessTokenPlayerBackend.string
+
+	// Standard output is used unless another output replaces it.
+	writeStdout := !externalCommand && (stdoutMode || (outputTemplate.string == nil && serveAddr.string == nil && hlsDir.string == nil))
+
+	if stateFile.string != nil && !writeStdout && !externalCommand && outputTemplate.string == nil {
+		stdErr.Println("option '--state-file' requires standard output, COMMAND or '--output'")
+		os.Exit(1)
 	}
 
-	token, err := getAcessToken(client, username, accessTokenOAuth.string, accessTokenDeviceID.string, variables)
+	policies, err := parseSinkFailure(outputFailure)
 	if err != nil {
-		stdErr.Printf("could not acquire access token: %v\n", err)
+		stdErr.Printf("invalid value for option '--output-failure': %v\n", err)
 		os.Exit(1)
 	}
 
-	playlists, err := getPlaylists(client, username, token)
+	if writeStdout && term.IsTerminal(int(os.Stdout.Fd())) && !forceOutput && !groupList {
+		stdErr.Println("[WARNING] You have not piped the output anywhere.")
+		stdErr.Println("          Outputting binary data to a terminal can be dangerous.")
+		stdErr.Println("          To bypass this safety feature, use the '--force-output' option.")
+		os.Exit(1)
+	}
+
+	client := newClient(stdErr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdown := notifyShutdown(cancel)
+
+	var server *streamServer
+	var httpServer *http.Server
+	if serveAddr.string != nil && !groupList {
+		ln, err := net.Listen("tcp", *serveAddr.string)
+		if err != nil {
+			stdErr.Printf("could not listen for viewers: %v\n", err)
+			os.Exit(1)
+		}
+
+		server = newStreamServer(stdErr)
+		httpServer = &http.Server{Handler: server}
+		go httpServer.Serve(ln)
+		stdErr.Printf("serving stream on http://%s/\n", ln.Addr())
+	}
+
+	waiting := (waitMode || loopMode) && target.kind == channelTarget
+
+	playlists, err := waitForPlaylists(ctx, client, target, waiting)
 	if err != nil {
-		stdErr.Printf("could not extract playlist: %v\n", err)
+		if ctx.Err() != nil {
+			os.Exit(signalExitCode(<-shutdown))
+		}
+		stdErr.Printf("%v\n", err)
 		os.Exit(1)
 	}
 
 	if groupList {
-		printGroups(playlists)
+		if listFormat == listFormatTable {
+			printGroups(os.Stderr, playlists)
+			os.Exit(0)
+		}
+		if err := writeGroups(os.Stdout, playlists, listFormat, listURLs); err != nil {
+			stdErr.Printf("could not list groups: %v\n", err)
+			os.Exit(1)
+		}
 		os.Exit(0)
 	}
 
-	var playlistURL string
-	switch groupSelect {
-	case "best":
-		best := findBest(playlists)
-		playlistURL = best.URL
-	default:
-		for _, p := range playlists {
-			if p.Group == groupSelect {
-				playlistURL = p.URL
-				break
-			}
+	selected, found := selectPlaylist(playlists, groupSelect)
+	if !found {
+		stdErr.Printf("could not find desired playlist quality")
+		os.Exit(2)
+	}
+
+	// primary is standard output or COMMAND, whichever the stream is
+	// written to, if any.
+	var primary io.Writer
+	var primaryName string
+	if writeStdout {
+		primary, primaryName = os.Stdout, "stdout"
+		if outputTemplate.string != nil || server != nil || hlsDir.string != nil {
+			// Let the other outputs carry on if standard output is closed.
+			ignoreBrokenPipe()
 		}
 	}
 
-	if playlistURL == "" {
-		stdErr.Printf("could not find desired playlist quality")
-		os.Exit(2)
+	var hls *hlsWriter
+	if hlsDir.string != nil {
+		if hls, err = newHLSWriter(*hlsDir.string, hlsWindow); err != nil {
+			stdErr.Printf("could not create HLS directory: %v\n", err)
+			os.Exit(1)
+		}
+		stdErr.Printf("publishing stream to %s\n", filepath.Join(*hlsDir.string, hlsPlaylistName))
 	}
 
-	var output io.Writer = os.Stdout
-	if len(flag.Args()) > 1 {
+	var cmd *command
+	if externalCommand {
 		var args []string
 		if externalArgs {
 			args = flag.Args()[2:]
 		}
-		cmd := exec.Command(flag.Arg(1), args...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if output, err = cmd.StdinPipe(); err != nil {
-			stdErr.Fatalf("could not acquire external command input: %v\n", err)
+		if cmd, err = startCommand(flag.Arg(1), args, commandExit, stdErr); err != nil {
+			stdErr.Fatalf("%v\n", err)
 		}
+		primary, primaryName = cmd, "command"
 
-		if err = cmd.Start(); err != nil {
-			stdErr.Fatalf("could not start external command: %v\n", err)
+		policies[primaryName] = sinkFailureStop
+		if commandExit == commandExitContinue {
+			policies[primaryName] = sinkFailureContinue
 		}
-		defer cmd.Wait()
 	}
 
-	tsURLs := make(chan string, 2)
-	done := make(chan error, 1)
-	go streamTs(client, tsURLs, output, done)
-
-	var currentSeq int
-	var needInit bool = true
-	urls, urlsErr := getURLs(client, playlistURL)
-	if !archiveMode && len(urls) > 1 {
-		currentSeq = urls[len(urls)-1].Seq
-	}
+	gaps := newGapReport(target.String())
 
+	var pollErr, streamErr error
 	for {
-		select {
-		case err := <-done:
-			close(tsURLs)
-			stdErr.Printf("error while streaming: %v\n", err)
-			os.Exit(2)
-		default:
-		}
-
-		if urlsErr != nil {
-			if urlsErr == errStreamOver {
-				close(tsURLs)
-				err := <-done
-				if err != nil {
-					stdErr.Printf("stream over with error: %v\n", err)
-					os.Exit(2)
+		out := newTee(int64(outputBuffer), stdErr)
+		var nextSeq int
+
+		// Every stream gets its own file when writing to a file.
+		var file *outputFile
+		if outputTemplate.string != nil {
+			if file, streamErr = createOutput(ctx, client, target, selected, *outputTemplate.string, stdErr); streamErr != nil {
+				break
+			}
+
+			// '--state-file' follows standard output or COMMAND if either is used.
+			stateName := file.stateName()
+			if stateFile.string != nil {
+				stateName = ""
+				if primary == nil {
+					stateName = *stateFile.string
 				}
-				stdErr.Println("stream over")
-				os.Exit(0)
 			}
 
-			stdErr.Printf("could not get prefetch URLs: %v\n", urlsErr)
+			var w io.Writer = file
+			if stateName != "" {
+				w, nextSeq = resumeOutput(ctx, client, target, stateName, w, stdErr)
+			}
+			out.add("output", w, policies["output"])
 		}
 
-		for _, url := range urls {
-			if url.Seq < currentSeq {
-				continue
+		if primary != nil {
+			w := primary
+			if stateFile.string != nil {
+				w, nextSeq = resumeOutput(ctx, client, target, *stateFile.string, w, stdErr)
 			}
+			out.add(primaryName, w, policies[primaryName])
+		}
+		if server != nil {
+			out.add("serve", server, policies["serve"])
+		}
+		if hls != nil {
+			out.add("hls", hls, policies["hls"])
+		}
+
+		pollErr, streamErr = stream(ctx, client, target, selected, out, archiveMode, nextSeq, gaps)
 
-			if url.Discontinuity {
-				needInit = true
+		if err := out.Close(); err != nil && streamErr == nil {
+			streamErr = err
+		}
+
+		if file != nil {
+			if err := file.Close(); err != nil && streamErr == nil {
+				streamErr = err
 			}
+		}
+
+		if !loopMode || target.kind != channelTarget || pollErr != nil || streamErr != nil || ctx.Err() != nil {
+			break
+		}
+
+		// The ended stream's playlist can linger for a moment, so give
+		// it time to go away before checking again.
+		stdErr.Println("stream over, waiting for the next stream...")
+		select {
+		case <-time.After(waitInterval):
+		case <-ctx.Done():
+		}
 
-			if url.MapURI != "" && needInit {
-				tsURLs <- url.MapURI
+		playlists, err := waitForPlaylists(ctx, client, target, true)
+		if err != nil {
+			if ctx.Err() == nil {
+				pollErr = err
 			}
-			needInit = false
+			break
+		}
 
-			tsURLs <- url.URI
+		if selected, found = selectPlaylist(playlists, groupSelect); !found {
+			pollErr = errors.New("could not find desired playlist quality")
+			break
+		}
+	}
 
-			currentSeq = url.Seq + 1
+	var sig os.Signal
+	select {
+	case sig = <-shutdown:
+	default:
+	}
+
+	if gaps.MissedSegments > 0 {
+		stdErr.Printf("recording is incomplete, missed %d segment(s) (~%.1fs) in %d gap(s)\n", gaps.MissedSegments, gaps.MissedDuration, len(gaps.Gaps))
+	}
+	if gapReportFile.string != nil {
+		if err := gaps.write(*gapReportFile.string); err != nil {
+			stdErr.Printf("could not write gap report: %v\n", err)
 		}
+	}
 
-		time.Sleep(time.Second * 1)
+	var exitCode int
+	switch {
+	case sig != nil:
+		stdErr.Println("stream interrupted")
+		exitCode = signalExitCode(sig)
+	case errors.Is(streamErr, errCommandExited):
+		// The exit status of the command is used below.
+		stdErr.Println("stream stopped")
+	case streamErr != nil:
+		stdErr.Printf("error while streaming: %v\n", streamErr)
+		exitCode = 2
+	case pollErr != nil:
+		stdErr.Printf("error while polling: %v\n", pollErr)
+		exitCode = 2
+	default:
+		stdErr.Println("stream over")
+	}
+
+	if hls != nil {
+		if err := hls.Close(); err != nil {
+			stdErr.Printf("could not end HLS playlist: %v\n", err)
+		}
+	}
 
-		urls, urlsErr = getURLs(client, playlistURL)
+	if server != nil {
+		server.Close()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		httpServer.Shutdown(shutdownCtx)
+		cancel()
 	}
+
+	if cmd != nil {
+		if err := cmd.Close(sig); err != nil && sig == nil {
+			stdErr.Printf("external command exited: %v\n", err)
+		}
+		if exitCode == 0 {
+			exitCode = cmd.exitCode()
+		}
+	}
+
+	os.Exit(exitCode)
+}
+
+// newClient returns a client configured from the command line options,
+// logging to logger.
+func newClient(logger *log.Logger) *twitch.Client {
+	if accessTokenDeviceID.string == nil {
+		accessTokenDeviceID.Set(randDeviceID())
+	}
+
+	variables := map[string]any{
+		"platform":   accessTokenPlatform,
+		"playerType": accessTokenPlayerType,
+	}
+	if accessTokenPlayerBackend.string != nil {
+		variables["playerBackend"] = *accessTokenPlayerBackend.string
+	}
+
+	return &twitch.Client{
+		HTTPClient: &http.Client{
+			Timeout: time.Second * 10,
+		},
+		Codecs:         codecs,
+		OAuthToken:     accessTokenOAuth.string,
+		DeviceID:       accessTokenDeviceID.string,
+		TokenVariables: variables,
+		Concurrency:    concurrency,
+		Retry: twitch.RetryPolicy{
+			MaxAttempts: retryAttempts,
+			Backoff:     retryBackoff,
+			MaxBackoff:  retryMaxBackoff,
+			Deadline:    retryDeadline,
+		},
+		Logger: logger,
+	}
+}
+
+// stream polls the media playlist of selected and writes its segments to output
+// until the stream ends, returning the errors of the poller and the output.
+// If nextSeq is non-zero, streaming resumes from that media sequence number.
+// Segments missed while polling are added to gaps, if set.
+func stream(ctx context.Context, client *twitch.Client, target target, selected twitch.PlaylistInfo, output io.Writer, archive bool, nextSeq int, gaps *gapReport) (pollErr, streamErr error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	segments := make(chan twitch.Segment, 2)
+	done := make(chan error, 1)
+	go func() {
+		err := client.Stream(ctx, segments, output)
+		if err != nil {
+			cancel()
+		}
+		done <- err
+	}()
+
+	if target.kind == clipTarget {
+		// Clips are a single file rather than a playlist.
+		segments <- twitch.Segment{URI: selected.URL}
+		close(segments)
+		return nil, <-done
+	}
+
+	poller := &twitch.Poller{
+		Client:   client,
+		URL:      selected.URL,
+		Archive:  archive || target.kind == videoTarget,
+		NextSeq:  nextSeq,
+		SkipAds:  skipAds,
+		Interval: pollInterval,
+		Refresh: func(ctx context.Context) (string, error) {
+			playlists, err := target.playlists(ctx, client)
+			if err != nil {
+				return "", err
+			}
+
+			// Stick to the variant that was originally selected, even if
+			// "best" would now resolve to something else, as switching codec
+			// mid-stream would break the output.
+			p, found := sameVariant(playlists, selected)
+			if !found {
+				return "", fmt.Errorf("playlist group %q is no longer available", selected.Group)
+			}
+
+			return p.URL, nil
+		},
+	}
+
+	if gaps != nil {
+		poller.OnGap = gaps.add
+	}
+
+	pollErr = poller.Run(ctx, segments)
+	return pollErr, <-done
 }
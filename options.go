@@ -1,14 +1,20 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"math/rand"
-	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
 	"rsc.io/getopt"
+
+	"github.com/Hakkin/twitchpipe/twitch"
 )
 
 type optionalString struct {
@@ -33,6 +39,73 @@ func (o *optionalString) String() string {
 	return *o.string
 }
 
+// singleString is an optionalString that can only be given once, for the
+// outputs of which a single one is supported.
+type singleString struct {
+	optionalString
+}
+
+func (o *singleString) Set(s string) error {
+	if o.string != nil {
+		return errors.New("can only be given once")
+	}
+
+	return o.optionalString.Set(s)
+}
+
+// byteSize is a number of bytes, optionally followed by a K, M, G or T
+// suffix for multiples of 1024, e.g. "500M" or "1.5GiB".
+type byteSize int64
+
+func (b *byteSize) Set(s string) error {
+	num := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(s), "B"), "I")
+
+	multiplier := float64(1)
+	if i := strings.IndexAny(num, "KMGT"); i >= 0 && i == len(num)-1 {
+		multiplier = math.Pow(1024, float64(strings.IndexByte("KMGT", num[i])+1))
+		num = num[:i]
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", s)
+	}
+
+	*b = byteSize(n * multiplier)
+	return nil
+}
+
+func (b *byteSize) String() string {
+	n := int64(*b)
+	for i := 4; i > 0; i-- {
+		if unit := int64(1) << (10 * i); n != 0 && n%unit == 0 {
+			return strconv.FormatInt(n/unit, 10) + string("KMGT"[i-1])
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// codecList is a comma-separated list of video codecs, most preferred first.
+type codecList []string
+
+func (c *codecList) Set(s string) error {
+	var list codecList
+	for _, name := range strings.Split(s, ",") {
+		codec, ok := normalizeCodec(name)
+		if !ok {
+			return fmt.Errorf("unknown codec %q, expected h264, h265 or av1", name)
+		}
+		list = append(list, codec)
+	}
+
+	*c = list
+	return nil
+}
+
+func (c *codecList) String() string {
+	return strings.Join(*c, ",")
+}
+
 var (
 	forceOutput        bool
 	forceOutputDefault = false
@@ -40,6 +113,21 @@ var (
 	usernameURL        bool
 	usernameURLDefault = false
 
+	videoMode        bool
+	videoModeDefault = false
+
+	waitMode        bool
+	waitModeDefault = false
+
+	loopMode        bool
+	loopModeDefault = false
+
+	waitInterval        time.Duration
+	waitIntervalDefault = time.Second * 30
+
+	waitMaxInterval        time.Duration
+	waitMaxIntervalDefault = time.Second * 30
+
 	archiveMode        bool
 	archiveModeDefault = false
 
@@ -52,15 +140,74 @@ var (
 	groupList        bool
 	groupListDefault = false
 
+	listFormat        string
+	listFormatDefault = listFormatTable
+
+	listURLs        bool
+	listURLsDefault = false
+
 	showVersion        bool
 	showVersionDefault = false
 
+	skipAds        bool
+	skipAdsDefault = false
+
+	pollInterval        time.Duration
+	pollIntervalDefault = time.Duration(0)
+
+	concurrency        int
+	concurrencyDefault = 1
+
+	retryAttempts        int
+	retryAttemptsDefault = 5
+
+	retryBackoff        time.Duration
+	retryBackoffDefault = time.Millisecond * 500
+
+	retryMaxBackoff        time.Duration
+	retryMaxBackoffDefault = time.Second * 8
+
+	retryDeadline        time.Duration
+	retryDeadlineDefault = time.Second * 30
+
+	outputExists        string
+	outputExistsDefault = outputExistsRename
+
+	splitDuration        time.Duration
+	splitDurationDefault = time.Duration(0)
+
+	splitSize byteSize
+
+	hlsWindow        int
+	hlsWindowDefault = 10
+
+	stdoutMode        bool
+	stdoutModeDefault = false
+
+	outputBuffer        = outputBufferDefault
+	outputBufferDefault = byteSize(64 << 20)
+
+	outputFailure        string
+	outputFailureDefault = sinkFailureContinue
+
+	commandExit        string
+	commandExitDefault = commandExitContinue
+
+	codecs        = codecsDefault
+	codecsDefault = codecList(twitch.DefaultCodecs)
+
 	accessTokenPlatform        string
 	accessTokenPlatformDefault = "web"
 
 	accessTokenPlayerType        string
 	accessTokenPlayerTypeDefault = "site"
 
+	gapReportFile  optionalString
+	outputTemplate singleString
+	stateFile      optionalString
+	serveAddr      singleString
+	hlsDir         singleString
+
 	accessTokenPlayerBackend optionalString
 	accessTokenOAuth         optionalString
 	accessTokenDeviceID      optionalString
@@ -68,11 +215,24 @@ var (
 
 func init() {
 	flag.BoolVar(&forceOutput, "f", forceOutputDefault, "Force output to standard output even if TTY is detected")
-	flag.BoolVar(&usernameURL, "u", usernameURLDefault, "Treat USERNAME as a URL")
+	flag.BoolVar(&usernameURL, "u", usernameURLDefault, "Treat USERNAME as a URL\n\tChannel, video and clip URLs are supported")
+	flag.BoolVar(&videoMode, "vod", videoModeDefault, "Treat USERNAME as a video (VOD) ID and download the whole video\n\tTwitch video URLs are detected automatically with '--url'")
+	flag.BoolVar(&waitMode, "w", waitModeDefault, "Wait for the channel to go live instead of exiting if it is offline")
+	flag.BoolVar(&loopMode, "l", loopModeDefault, "Wait for the channel to go live again once the stream ends\n\tImplies '--wait'")
 	flag.BoolVar(&archiveMode, "a", archiveModeDefault, "Start downloading from the oldest segment rather than the newest")
-	flag.StringVar(&groupSelect, "g", groupSelectDefault, "Select specified playlist group\n\t\"best\" will select the best available group")
+	flag.StringVar(&groupSelect, "g", groupSelectDefault, "Select playlist group, trying a comma-separated list of alternatives in order (e.g. \"720p60,720p,best\")\n"+
+		"\tAlternatives are \"best\", \"worst\", a resolution such as \"720p\" or \"720p60\", or a group such as \"audio_only\"\n"+
+		"\tConstraints such as \"height<=720\", \"fps>=60\", \"codec=h264\" or \"bitrate<3000k\" limit every alternative")
 	flag.BoolVar(&groupList, "G", groupListDefault, "List available playlist groups and exit")
+	flag.StringVar(&listFormat, "format", listFormatDefault, "Format of '--list-groups', \"table\" on standard error, or \"json\" or \"csv\" on standard output")
+	flag.BoolVar(&listURLs, "list-urls", listURLsDefault, "Include playlist URLs in '--list-groups' in the JSON and CSV formats")
 	flag.BoolVar(&showVersion, "v", showVersionDefault, "Show version information and exit")
+	flag.Var(&outputTemplate, "o", "Write the stream to the file named by the given template instead of standard output (optional)\n"+
+		"\tThe placeholders {channel}, {id}, {title}, {game}, {quality} and {broadcast_id} are replaced with\n"+
+		"\tthe channel's username and numerical ID, the title, the game, the playlist group and the broadcast ID\n"+
+		"\t{date} is replaced with the current UTC time, formatted with Go's layout if given, e.g. {date:2006-01-02}\n"+
+		"\t{part} is replaced with the part number when splitting, otherwise '_part<N>' is added before the extension\n"+
+		"\tPlaceholder values are sanitized and missing directories are created")
 	getopt.Aliases(
 		"f", "force-output",
 		"u", "url",
@@ -80,13 +240,57 @@ func init() {
 		"g", "group",
 		"G", "list-groups",
 		"v", "version",
+		"w", "wait",
+		"l", "loop",
+		"o", "output",
 	)
 
-	flag.StringVar(&accessTokenPlatform, "access-token-platform", accessTokenPlatformDefault, "The platform to send when acquiring an access token")
-	flag.StringVar(&accessTokenPlayerType, "access-token-player-type", accessTokenPlayerTypeDefault, "The player type to send when acquiring an access token")
-	flag.Var(&accessTokenPlayerBackend, "access-token-player-backend", "The player backend to send when acquiring an access token (optional)")
-	flag.Var(&accessTokenOAuth, "access-token-oauth", "OAuth token to send when acquiring an access token (optional)")
-	flag.Var(&accessTokenDeviceID, "access-token-device-id", "Device ID to send when acquiring an access token (optional)\n\tIf no device ID is specified, one will be generated randomly")
+	flag.DurationVar(&waitInterval, "wait-interval", waitIntervalDefault, "Interval between checks while waiting for the channel to go live")
+	flag.DurationVar(&waitMaxInterval, "wait-max-interval", waitMaxIntervalDefault, "Maximum interval between checks while waiting for the channel to go live\n\tThe interval doubles after every check until it reaches this value")
+	flag.Var(&serveAddr, "serve", "Serve the stream over HTTP on the specified address (e.g. \":8080\") instead of writing it to standard output (optional)\n"+
+		"\tAny number of viewers can connect at any time, starting at the next segment")
+	flag.Var(&hlsDir, "hls", "Republish the stream as a live HLS playlist, index.m3u8, in the specified directory instead of writing it to standard output (optional)")
+	flag.IntVar(&hlsWindow, "hls-window", hlsWindowDefault, "Number of segments kept in the '--hls' playlist, older segments are deleted")
+	flag.BoolVar(&stdoutMode, "stdout", stdoutModeDefault, "Also write the stream to standard output when using '--output', '--serve' or '--hls'\n\tCannot be used together with COMMAND, whose output goes to standard output")
+	flag.Var(&outputBuffer, "output-buffer", "Amount of stream data buffered for each output (e.g. \"64M\")\n"+
+		"\tA slow output only holds up the others once its buffer is full")
+	flag.StringVar(&outputFailure, "output-failure", outputFailureDefault, "What to do when an output fails, \"stop\" streaming or \"continue\" with the remaining outputs\n"+
+		"\tPolicies can be set per output as a comma-separated list of NAME=POLICY, where NAME is one of\n"+
+		"\tstdout, output, serve or hls, e.g. \"output=stop,serve=continue\"\n"+
+		"\tSee '--command-exit' for COMMAND")
+	flag.StringVar(&commandExit, "command-exit", commandExitDefault, "What to do when COMMAND exits, \"exit\" with its exit status, \"restart\" it at the next segment\n"+
+		"\tor \"continue\" with the remaining outputs, exiting like \"exit\" if there are none")
+	flag.Var(&stateFile, "state-file", "Record the last segment written in the specified file and, if it is for the same broadcast, resume after it (optional)\n"+
+		"\tThis is done automatically next to the output file when using '--output-exists append'")
+	flag.Var(&gapReportFile, "gap-report", "Write a JSON summary of segments missed while polling to the specified file on exit (optional)")
+
+	registerStreamFlags(flag.CommandLine)
+}
+
+// registerStreamFlags registers the options shared by twitchpipe and its
+// record subcommand on fs.
+func registerStreamFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&skipAds, "skip-ads", skipAdsDefault, "Withhold segments belonging to stitched advertisements from the output")
+	fs.IntVar(&concurrency, "concurrency", concurrencyDefault, "Number of segments to download in parallel\n\tSegments are still written in order, but are buffered in memory when greater than 1")
+	fs.IntVar(&retryAttempts, "retry-attempts", retryAttemptsDefault, "Maximum number of attempts to download a segment before skipping it\n\t0 will retry indefinitely")
+	fs.DurationVar(&retryBackoff, "retry-backoff", retryBackoffDefault, "Delay before retrying a failed segment download, doubled after every attempt")
+	fs.DurationVar(&retryMaxBackoff, "retry-max-backoff", retryMaxBackoffDefault, "Maximum delay between segment download attempts")
+	fs.DurationVar(&retryDeadline, "retry-deadline", retryDeadlineDefault, "Maximum time a segment may fall behind the live edge while its download is retried before skipping it\n\tDoes not apply to VODs and streams that have ended\n\t0 will retry indefinitely")
+	fs.StringVar(&outputExists, "output-exists", outputExistsDefault, "What to do if the output file already exists\n"+
+		"\t\"rename\" appends a number to the filename, \"append\", \"overwrite\" or \"fail\"")
+	fs.DurationVar(&splitDuration, "split-duration", splitDurationDefault, "Split the output file into numbered parts of at most the given duration (e.g. \"1h\")\n"+
+		"\tParts are cut between segments, so each can be played on its own")
+	fs.Var(&splitSize, "split-size", "Split the output file into numbered parts once they reach the given size (e.g. \"2G\")\n"+
+		"\tParts are cut between segments, so each can be played on its own")
+	fs.Var(&codecs, "codecs", "Comma-separated list of the video codecs that can be played, most preferred first\n"+
+		"\tOnly these are requested, and groups are selected preferring them in order")
+	fs.DurationVar(&pollInterval, "poll-interval", pollIntervalDefault, "Fixed interval between playlist reloads (e.g. \"2s\")\n\tIf unset, the interval is derived from the playlist's segment durations")
+
+	fs.StringVar(&accessTokenPlatform, "access-token-platform", accessTokenPlatformDefault, "The platform to send when acquiring an access token")
+	fs.StringVar(&accessTokenPlayerType, "access-token-player-type", accessTokenPlayerTypeDefault, "The player type to send when acquiring an access token")
+	fs.Var(&accessTokenPlayerBackend, "access-token-player-backend", "The player backend to send when acquiring an access token (optional)")
+	fs.Var(&accessTokenOAuth, "access-token-oauth", "OAuth token to send when acquiring an access token (optional)")
+	fs.Var(&accessTokenDeviceID, "access-token-device-id", "Device ID to send when acquiring an access token (optional)\n\tIf no device ID is specified, one will be generated randomly")
 }
 
 func printVersion() {
@@ -123,6 +327,8 @@ func printVersion() {
 
 func printUsage() {
 	stdErr.Println("Usage: twitchpipe [OPTIONS...] <USERNAME> [COMMAND...]")
+	stdErr.Println("       twitchpipe [OPTIONS...] --vod <ID> [COMMAND...]")
+	stdErr.Println("       twitchpipe record [OPTIONS...] <USERNAMES...>")
 	stdErr.Println()
 	stdErr.Println("If COMMAND is specified, it will be executed and stream data will be \nwritten to its standard input.")
 	stdErr.Println("Otherwise, stream data will be written to standard output.")
@@ -131,34 +337,23 @@ func printUsage() {
 	getopt.PrintDefaults()
 }
 
-func findBest(playlists []playlistInfo) playlistInfo {
-	var best playlistInfo
-	var highBitrate int
-	for _, p := range playlists {
-		if p.Group == "chunked" {
-			return p
-		}
-
-		if p.Bandwidth > highBitrate {
-			highBitrate = p.Bandwidth
-			best = p
-		}
-	}
-
-	return best
-}
-
-func printGroups(playlists []playlistInfo) {
+// printGroups writes playlists to w as a table, marking the one "best" selects.
+func printGroups(w io.Writer, playlists []twitch.PlaylistInfo) {
 	columns := []*struct {
 		title   string
 		length  int
 		content []string
-		fn      func(p playlistInfo) string
+		fn      func(p twitch.PlaylistInfo) string
 	}{
-		{"Group", 0, nil, func(p playlistInfo) string { return p.Group }},
-		{"Name", 0, nil, func(p playlistInfo) string { return p.Name }},
-		{"Resolution", 0, nil, func(p playlistInfo) string { return fmt.Sprintf("%dx%d", p.Width, p.Height) }},
-		{"Codec", 0, nil, func(p playlistInfo) string {
+		{"Group", 0, nil, func(p twitch.PlaylistInfo) string { return p.Group }},
+		{"Name", 0, nil, func(p twitch.PlaylistInfo) string { return p.Name }},
+		{"Resolution", 0, nil, func(p twitch.PlaylistInfo) string {
+			if p.Width == 0 && p.Height != 0 {
+				return fmt.Sprintf("%dp", p.Height)
+			}
+			return fmt.Sprintf("%dx%d", p.Width, p.Height)
+		}},
+		{"Codec", 0, nil, func(p twitch.PlaylistInfo) string {
 			var cs string
 			for _, c := range strings.Split(p.Codec, ",") {
 				cs += strings.SplitN(c, ".", 2)[0] + "+"
@@ -168,7 +363,7 @@ func printGroups(playlists []playlistInfo) {
 			}
 			return cs
 		}},
-		{"Bitrate", 0, nil, func(p playlistInfo) string { return fmt.Sprintf("%dk", p.Bandwidth/1024) }},
+		{"Bitrate", 0, nil, func(p twitch.PlaylistInfo) string { return fmt.Sprintf("%dk", p.Bandwidth/1024) }},
 	}
 
 	for _, c := range columns {
@@ -186,32 +381,33 @@ func printGroups(playlists []playlistInfo) {
 	}
 
 	for _, c := range columns {
-		fmt.Fprint(os.Stderr, c.title)
+		fmt.Fprint(w, c.title)
 		if c.length-len(c.title) > 0 {
-			fmt.Fprint(os.Stderr, strings.Repeat(" ", c.length-len(c.title)))
+			fmt.Fprint(w, strings.Repeat(" ", c.length-len(c.title)))
 		}
-		fmt.Fprint(os.Stderr, " ")
+		fmt.Fprint(w, " ")
 	}
 
-	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(w)
 
-	best := findBest(playlists)
+	best, found := selectPlaylist(playlists, "best")
 
 	for i := range playlists {
 		for _, c := range columns {
 			content := c.content[i]
-			fmt.Fprint(os.Stderr, content)
+			fmt.Fprint(w, content)
 			if c.length-len(content) > 0 {
-				fmt.Fprint(os.Stderr, strings.Repeat(" ", c.length-len(content)))
+				fmt.Fprint(w, strings.Repeat(" ", c.length-len(content)))
 			}
-			fmt.Fprint(os.Stderr, " ")
+			fmt.Fprint(w, " ")
 		}
 
-		if playlists[i].Group == best.Group {
-			fmt.Fprint(os.Stderr, "(best)")
+		// Variants can share a group, so compare by URL as writeGroups does.
+		if found && playlists[i].URL == best.URL {
+			fmt.Fprint(w, "(best)")
 		}
 
-		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(w)
 	}
 }
 
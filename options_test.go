@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestByteSize(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp byteSize
+		err bool
+	}{
+		{"1000", 1000, false},
+		{"500K", 500 << 10, false},
+		{"2g", 2 << 30, false},
+		{"1.5GiB", 3 << 29, false},
+		{"1TB", 1 << 40, false},
+		{"M", 0, true},
+		{"-1", 0, true},
+		{"1X", 0, true},
+	}
+
+	for _, test := range tests {
+		var b byteSize
+		err := b.Set(test.s)
+		if (err != nil) != test.err {
+			t.Errorf("Set(%q) returned error %v", test.s, err)
+			continue
+		}
+		if b != test.exp {
+			t.Errorf("Set(%q) = %d, expected %d", test.s, b, test.exp)
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	tests := []struct {
+		b   byteSize
+		exp string
+	}{
+		{0, "0"},
+		{1000, "1000"},
+		{64 << 20, "64M"},
+		{3 << 29, "1536M"},
+		{1 << 40, "1T"},
+	}
+
+	for _, test := range tests {
+		if got := test.b.String(); got != test.exp {
+			t.Errorf("byteSize(%d).String() = %q, expected %q", int64(test.b), got, test.exp)
+		}
+	}
+}
+
+func TestSingleString(t *testing.T) {
+	var o singleString
+	if err := o.Set("first.ts"); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Set("second.ts"); err == nil {
+		t.Error("second Set succeeded")
+	}
+	if *o.string != "first.ts" {
+		t.Errorf("value is %q, expected the first one", *o.string)
+	}
+}
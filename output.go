@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+// dateLayoutDefault is the layout of {date} placeholders without one,
+// matching `date -u '+%Y_%m_%d_%H_%M_%S_(%Z)'`.
+const dateLayoutDefault = "2006_01_02_15_04_05_(MST)"
+
+// maxComponentLength is the longest file or directory name, in bytes,
+// supported by common filesystems.
+const maxComponentLength = 255
+
+const (
+	outputExistsRename    = "rename"
+	outputExistsAppend    = "append"
+	outputExistsOverwrite = "overwrite"
+	outputExistsFail      = "fail"
+)
+
+// outputFields are the values of the placeholders of an output template.
+// Fields that are not known are empty.
+type outputFields struct {
+	Channel   string
+	ChannelID string
+	Title     string
+	Game      string
+	Quality   string
+	Broadcast string
+	Date      time.Time
+	// Part is the number of the part of a split output, counting from 1.
+	Part int
+}
+
+// value returns the value of the placeholder {name:arg}, or false if there is
+// no such placeholder. Only {date} takes an argument, its layout.
+func (f outputFields) value(name, arg string) (string, bool) {
+	if name == "date" {
+		if arg == "" {
+			arg = dateLayoutDefault
+		}
+		return f.Date.UTC().Format(arg), true
+	}
+
+	if arg != "" {
+		return "", false
+	}
+
+	switch name {
+	case "channel":
+		return f.Channel, true
+	case "id":
+		return f.ChannelID, true
+	case "title":
+		return f.Title, true
+	case "game":
+		return f.Game, true
+	case "quality":
+		return f.Quality, true
+	case "broadcast_id":
+		return f.Broadcast, true
+	case "part":
+		return strconv.Itoa(f.Part), true
+	}
+
+	return "", false
+}
+
+// expandOutput replaces the placeholders of the output template tmpl with the
+// values in f. Values are sanitized so that they cannot add path separators,
+// and every file or directory name containing a placeholder is made valid on
+// all common platforms.
+func expandOutput(tmpl string, f outputFields) (string, error) {
+	var path, component strings.Builder
+	var expanded bool
+	endComponent := func() {
+		c := component.String()
+		if expanded {
+			c = sanitizeComponent(c)
+		}
+		path.WriteString(c)
+		component.Reset()
+		expanded = false
+	}
+
+	for rest := tmpl; rest != ""; {
+		switch c := rest[0]; {
+		case c == '{':
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated placeholder in output template %q", tmpl)
+			}
+
+			name, arg, _ := strings.Cut(rest[1:end], ":")
+			v, ok := f.value(name, arg)
+			if !ok {
+				return "", fmt.Errorf("unknown placeholder %s in output template", rest[:end+1])
+			}
+			component.WriteString(safeName(v))
+			expanded = true
+			rest = rest[end+1:]
+		case c == '/' || (runtime.GOOS == "windows" && c == '\\'):
+			endComponent()
+			path.WriteByte(c)
+			rest = rest[1:]
+		default:
+			component.WriteByte(c)
+			rest = rest[1:]
+		}
+	}
+	endComponent()
+
+	return filepath.FromSlash(path.String()), nil
+}
+
+// safeName replaces control characters and characters that are not allowed
+// in filenames on common platforms with underscores.
+func safeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// sanitizeComponent makes the file or directory name c valid on Windows, which
+// does not allow trailing dots or spaces or device names such as "CON", and
+// shortens it to maxComponentLength, keeping its extension.
+func sanitizeComponent(c string) string {
+	c = strings.TrimRight(c, ". ")
+	if c == "" {
+		return "_"
+	}
+
+	base, _, _ := strings.Cut(c, ".")
+	switch strings.ToUpper(strings.TrimRight(base, " ")) {
+	case "CON", "PRN", "AUX", "NUL",
+		"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+		"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9":
+		c = "_" + c
+	}
+
+	if len(c) > maxComponentLength {
+		ext := filepath.Ext(c)
+		if len(ext) > maxComponentLength/4 {
+			ext = ""
+		}
+
+		c = c[:maxComponentLength-len(ext)]
+		for !utf8.ValidString(c) {
+			c = c[:len(c)-1]
+		}
+		c += ext
+	}
+
+	return c
+}
+
+// validOutputExists reports whether policy is a valid --output-exists value.
+func validOutputExists(policy string) bool {
+	switch policy {
+	case outputExistsRename, outputExistsAppend, outputExistsOverwrite, outputExistsFail:
+		return true
+	}
+	return false
+}
+
+// openOutput creates the file name and its parent directories. If the file
+// already exists, policy decides whether it is renamed by appending a number,
+// appended to, overwritten, or whether an error is returned.
+func openOutput(name, policy string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+
+	switch policy {
+	case outputExistsAppend:
+		return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	case outputExistsOverwrite:
+		return os.Create(name)
+	case outputExistsFail:
+		return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !errors.Is(err, os.ErrExist) {
+			return f, err
+		}
+		name = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
+
+// splitEarly is the fraction of --split-duration or --split-size after which a
+// part is already ended at a discontinuity or initialization section change.
+const splitEarly = 0.9
+
+// splitting reports whether file outputs are split into parts.
+func splitting() bool {
+	return splitDuration > 0 || splitSize > 0
+}
+
+// partName returns name with the part number inserted before its extension
+// if file outputs are split into parts.
+func partName(name string, part int) string {
+	if !splitting() {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s_part%d%s", strings.TrimSuffix(name, ext), part, ext)
+}
+
+// outputFile writes a stream to a file. If --split-duration or --split-size
+// is set, the stream is split into numbered parts at segment boundaries,
+// each of which can be played on its own.
+type outputFile struct {
+	// name returns the filename of the given part, counting from 1.
+	name   func(part int) (string, error)
+	logger *log.Logger
+	// done, if set, is called with the name of every finished part that was kept.
+	done func(name string)
+	// ended, if set, is called with every segment written completely.
+	ended func(segment twitch.Segment)
+
+	file *os.File
+	part int
+	size int64
+	// duration is the duration of the segments in the part, in seconds.
+	duration float64
+	mapURI   string
+}
+
+// newOutputFile opens the first part of an output file.
+func newOutputFile(name func(part int) (string, error), logger *log.Logger) (*outputFile, error) {
+	o := &outputFile{name: name, logger: logger}
+	if err := o.next(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// createOutput looks up the details of t and opens an output file named by
+// expanding the output template tmpl with them.
+func createOutput(ctx context.Context, client *twitch.Client, t target, selected twitch.PlaylistInfo, tmpl string, logger *log.Logger) (*outputFile, error) {
+	fields := lookupOutputFields(ctx, client, t, selected, logger)
+	return newOutputFile(func(part int) (string, error) {
+		fields.Date, fields.Part = time.Now(), part
+		name, err := expandOutput(tmpl, fields)
+		if err != nil || strings.Contains(tmpl, "{part}") {
+			return name, err
+		}
+		return partName(name, part), nil
+	}, logger)
+}
+
+// next closes the current part, if any, and opens the next one.
+func (o *outputFile) next() error {
+	if o.file != nil {
+		if err := o.Close(); err != nil {
+			return err
+		}
+	}
+
+	o.part++
+	name, err := o.name(o.part)
+	if err != nil {
+		return err
+	}
+
+	f, err := openOutput(name, outputExists)
+	if err != nil {
+		return fmt.Errorf("could not open output file: %w", err)
+	}
+
+	o.logger.Printf("writing to %s\n", f.Name())
+	o.file, o.size, o.duration = f, 0, 0
+	return nil
+}
+
+func (o *outputFile) Write(p []byte) (int, error) {
+	n, err := o.file.Write(p)
+	o.size += int64(n)
+	return n, err
+}
+
+// BeginSegment implements twitch.SegmentWriter. It starts a new part before
+// segment once the current part is full, or nearly full if segment follows
+// a discontinuity or changes the initialization section.
+func (o *outputFile) BeginSegment(segment twitch.Segment) (bool, error) {
+	limit := 1.0
+	if segment.Discontinuity || segment.MapURI != o.mapURI {
+		limit = splitEarly
+	}
+	o.mapURI = segment.MapURI
+
+	if o.size == 0 || !o.full(segment, limit) {
+		o.duration += segment.Duration
+		return false, nil
+	}
+
+	if err := o.next(); err != nil {
+		return false, err
+	}
+	o.duration = segment.Duration
+	return true, nil
+}
+
+// EndSegment implements twitch.SegmentWriter.
+func (o *outputFile) EndSegment(segment twitch.Segment) error {
+	if o.ended != nil {
+		o.ended(segment)
+	}
+	return nil
+}
+
+// full reports whether the current part has reached the given fraction of
+// --split-size, or would exceed that of --split-duration with segment added.
+func (o *outputFile) full(segment twitch.Segment, fraction float64) bool {
+	if splitSize > 0 && float64(o.size) >= float64(splitSize)*fraction {
+		return true
+	}
+	return splitDuration > 0 && o.duration+segment.Duration > splitDuration.Seconds()*fraction
+}
+
+// Name returns the name of the current part.
+func (o *outputFile) Name() string {
+	return o.file.Name()
+}
+
+// stateName returns the name of the state file kept next to o. Only outputs
+// that are appended to and not split can be resumed, so it returns "" for
+// others, including a nil o.
+func (o *outputFile) stateName() string {
+	if o == nil || outputExists != outputExistsAppend || splitting() {
+		return ""
+	}
+	return o.Name() + ".state"
+}
+
+// Close closes the current part, removing it if it is empty.
+func (o *outputFile) Close() error {
+	err := o.file.Close()
+
+	name := o.file.Name()
+	if info, statErr := os.Stat(name); statErr == nil && info.Size() == 0 {
+		o.logger.Printf("output file %s was empty, removing...\n", name)
+		os.Remove(name)
+	} else if o.done != nil {
+		o.done(name)
+	}
+
+	return err
+}
+
+// lookupOutputFields returns the output template fields of t. If the details
+// of t cannot be looked up, the failure is logged and the fields are left empty.
+func lookupOutputFields(ctx context.Context, client *twitch.Client, t target, selected twitch.PlaylistInfo, logger *log.Logger) outputFields {
+	f := outputFields{
+		Quality: selected.Group,
+		Date:    time.Now(),
+	}
+
+	switch t.kind {
+	case channelTarget:
+		f.Channel = t.name
+
+		users, err := client.GetUsers(ctx, []string{t.name})
+		if err == nil && users[0] == nil {
+			err = errors.New("user not found")
+		}
+		if err != nil {
+			logger.Printf("could not look up channel for output filename: %v\n", err)
+			break
+		}
+
+		user := users[0]
+		f.ChannelID, f.Title = user.ID, user.Title
+		if user.Stream != nil {
+			f.Game, f.Broadcast = user.Stream.Game, user.Stream.ID
+		}
+	case videoTarget:
+		video, err := client.GetVideo(ctx, t.name)
+		if err != nil {
+			logger.Printf("could not look up video for output filename: %v\n", err)
+			break
+		}
+
+		f.Channel, f.ChannelID = video.OwnerLogin, video.OwnerID
+		f.Title, f.Game = video.Title, video.Game
+	case clipTarget:
+		clip, err := client.GetClip(ctx, t.name)
+		if err != nil {
+			logger.Printf("could not look up clip for output filename: %v\n", err)
+			break
+		}
+
+		f.Channel, f.ChannelID = clip.BroadcasterLogin, clip.BroadcasterID
+		f.Title, f.Game = clip.Title, clip.Game
+	}
+
+	return f
+}
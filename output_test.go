@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+func TestSafeName(t *testing.T) {
+	tests := []struct {
+		name string
+		exp  string
+	}{
+		{"2019_10_12_12_00_00_(UTC)", "2019_10_12_12_00_00_(UTC)"},
+		{"a/b\\c:d*e?f\"g<h>i|j", "a_b_c_d_e_f_g_h_i_j"},
+		{"title\twith\nnewline\x7f", "title_with_newline_"},
+		{"日本語", "日本語"},
+	}
+
+	for _, test := range tests {
+		if got := safeName(test.name); got != test.exp {
+			t.Errorf("safeName(%q) = %q, expected %q", test.name, got, test.exp)
+		}
+	}
+}
+
+func TestSanitizeComponent(t *testing.T) {
+	long := strings.Repeat("日", 100) + ".ts"
+
+	tests := []struct {
+		name string
+		exp  string
+	}{
+		{"title", "title"},
+		{"title. . ", "title"},
+		{"..", "_"},
+		{"", "_"},
+		{"con", "_con"},
+		{"LPT1.ts", "_LPT1.ts"},
+		{"console.ts", "console.ts"},
+		{long, strings.Repeat("日", 84) + ".ts"},
+	}
+
+	for _, test := range tests {
+		if got := sanitizeComponent(test.name); got != test.exp {
+			t.Errorf("sanitizeComponent(%q) = %q, expected %q", test.name, got, test.exp)
+		}
+	}
+}
+
+func TestExpandOutput(t *testing.T) {
+	fields := outputFields{
+		Channel:   "username",
+		ChannelID: "123",
+		Title:     "a/b: c?",
+		Game:      "Just Chatting",
+		Quality:   "chunked",
+		Broadcast: "456",
+		Date:      time.Date(2019, 10, 12, 12, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		tmpl string
+		exp  string
+		err  bool
+	}{
+		{"{channel}/{date}.ts", "username/2019_10_12_12_00_00_(UTC).ts", false},
+		{"{channel}/{date:2006-01-02}_{title}.ts", "username/2019-10-12_a_b_ c_.ts", false},
+		{"/rec/{id}-{broadcast_id}/{game} {quality}.ts", "/rec/123-456/Just Chatting chunked.ts", false},
+		{"{date:2006/01}.ts", "2019_10.ts", false},
+		{"{title}", "a_b_ c_", false},
+		{"out.ts", "out.ts", false},
+		{"{channel", "", true},
+		{"{unknown}.ts", "", true},
+		{"{title:x}.ts", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := expandOutput(test.tmpl, fields)
+		if (err != nil) != test.err {
+			t.Errorf("expandOutput(%q) returned error %v", test.tmpl, err)
+			continue
+		}
+		if got != filepath.FromSlash(test.exp) {
+			t.Errorf("expandOutput(%q) = %q, expected %q", test.tmpl, got, test.exp)
+		}
+	}
+
+	// Empty fields must not produce empty or parent directory names.
+	got, err := expandOutput("{channel}/{title}.ts", outputFields{Title: ".."})
+	if err != nil || got != filepath.FromSlash("_/...ts") {
+		t.Errorf("expandOutput with empty fields = %q, %v", got, err)
+	}
+}
+
+func TestOpenOutput(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "sub", "out.ts")
+
+	open := func(policy string) (string, error) {
+		f, err := openOutput(name, policy)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		_, err = f.WriteString(policy)
+		return f.Name(), err
+	}
+
+	tests := []struct {
+		policy string
+		file   string
+		exp    string
+		err    bool
+	}{
+		{outputExistsRename, "out.ts", "rename", false},
+		{outputExistsRename, "out (1).ts", "rename", false},
+		{outputExistsRename, "out (2).ts", "rename", false},
+		{outputExistsAppend, "out.ts", "renameappend", false},
+		{outputExistsOverwrite, "out.ts", "overwrite", false},
+		{outputExistsFail, "", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := open(test.policy)
+		if (err != nil) != test.err {
+			t.Errorf("openOutput(%q) returned error %v", test.policy, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+
+		if exp := filepath.Join(dir, "sub", test.file); got != exp {
+			t.Errorf("openOutput(%q) opened %q, expected %q", test.policy, got, exp)
+		}
+		if data, _ := os.ReadFile(got); string(data) != test.exp {
+			t.Errorf("openOutput(%q) left %q, expected %q", test.policy, data, test.exp)
+		}
+	}
+}
+
+func TestOutputFileSplit(t *testing.T) {
+	dir := t.TempDir()
+	defer func(d time.Duration, s byteSize) { splitDuration, splitSize = d, s }(splitDuration, splitSize)
+	splitDuration, splitSize = time.Second*10, 0
+
+	var done []string
+	o, err := newOutputFile(func(part int) (string, error) {
+		return partName(filepath.Join(dir, "out.ts"), part), nil
+	}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.done = func(name string) { done = append(done, filepath.Base(name)) }
+
+	segments := []struct {
+		segment twitch.Segment
+		reinit  bool
+	}{
+		{twitch.Segment{Duration: 4}, false},
+		{twitch.Segment{Duration: 4}, false},
+		// Would exceed 10s.
+		{twitch.Segment{Duration: 4}, true},
+		{twitch.Segment{Duration: 4}, false},
+		// Past 90% of 10s at a discontinuity.
+		{twitch.Segment{Duration: 2, Discontinuity: true}, true},
+	}
+
+	for i, s := range segments {
+		reinit, err := o.BeginSegment(s.segment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reinit != s.reinit {
+			t.Errorf("segment %d: BeginSegment returned %v, expected %v", i, reinit, s.reinit)
+		}
+		fmt.Fprintf(o, "%d", i)
+	}
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"out_part1.ts", "out_part2.ts", "out_part3.ts"}
+	if !reflect.DeepEqual(done, exp) {
+		t.Errorf("wrote parts %v, expected %v", done, exp)
+	}
+	for i, content := range []string{"01", "23", "4"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, exp[i])); string(data) != content {
+			t.Errorf("part %d contains %q, expected %q", i+1, data, content)
+		}
+	}
+}
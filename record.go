@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"rsc.io/getopt"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+var (
+	recordPrintFilenames        bool
+	recordPrintFilenamesDefault = false
+
+	recordCheckInterval        time.Duration
+	recordCheckIntervalDefault = time.Minute
+
+	recordOutput        string
+	recordOutputDefault = "{channel}/{date}.ts"
+
+	recordShowHelp bool
+
+	recordFilenameCommand optionalString
+)
+
+// recordLiveAttempts and recordLiveInterval bound how long a recorder
+// waits for the playlist of a stream that was just reported live.
+const recordLiveAttempts = 6
+
+var (
+	recordLiveInterval = time.Second * 10
+	recordRestartDelay = time.Second * 5
+)
+
+func printRecordUsage(fs *getopt.FlagSet) {
+	stdErr.Println("Usage: twitchpipe record [OPTIONS...] <USERNAMES...>")
+	stdErr.Println()
+	stdErr.Println("Monitors the given channels and records every stream they broadcast")
+	stdErr.Println("to separate files, starting from the oldest available segment.")
+	stdErr.Println()
+	stdErr.Println("Options:")
+	fs.PrintDefaults()
+}
+
+// record implements the record subcommand and returns the exit status.
+func record(args []string) int {
+	fs := getopt.NewFlagSet("record", flag.ContinueOnError)
+	fs.BoolVar(&recordShowHelp, "h", false, "Print this help text")
+	fs.BoolVar(&recordPrintFilenames, "p", recordPrintFilenamesDefault, "Print filenames to standard output once stream ends")
+	fs.StringVar(&groupSelect, "g", groupSelectDefault, "Select playlist group to record, with the same syntax as '--group' of twitchpipe")
+	fs.Var(&recordFilenameCommand, "f", "Command that will be evaluated to get output filename (optional)\n"+
+		"\tFilename will be read from the command's standard output\n"+
+		"\tThe environment variables $username and $id hold the streamer's Twitch username and numerical ID\n"+
+		"\tFilenames are sanitized and '.ts' is appended, so the final filename may not match the command's output\n"+
+		"\tThe file is written to the directory named after the username\n"+
+		"\tCannot be used together with '--output'")
+	fs.StringVar(&recordOutput, "o", recordOutputDefault, "Output filename template, see 'twitchpipe --help' for the available placeholders")
+	fs.DurationVar(&recordCheckInterval, "check-interval", recordCheckIntervalDefault, "Interval between checks of the channels' live status\n\tPubSub is used to notice streams starting sooner")
+	fs.Aliases(
+		"h", "help",
+		"p", "print-filenames",
+		"g", "group",
+		"f", "filename-command",
+		"o", "output",
+	)
+	registerStreamFlags(fs.FlagSet)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() { printRecordUsage(fs) }
+
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	if recordShowHelp {
+		printRecordUsage(fs)
+		return 0
+	}
+
+	if _, err := parseSelection(groupSelect); err != nil {
+		stdErr.Printf("invalid value for option '--group': %v\n", err)
+		return 1
+	}
+
+	if recordFilenameCommand.string != nil {
+		if *recordFilenameCommand.string == "" {
+			stdErr.Println("option '--filename-command' cannot be empty")
+			return 1
+		}
+		if recordOutput != recordOutputDefault {
+			stdErr.Println("option '--filename-command' cannot be used together with '--output'")
+			return 1
+		}
+	}
+
+	if _, err := expandOutput(recordOutput, outputFields{}); err != nil {
+		stdErr.Printf("%v\n", err)
+		return 1
+	}
+
+	if !validOutputExists(outputExists) {
+		stdErr.Printf("invalid value %q for option '--output-exists'\n", outputExists)
+		return 1
+	}
+
+	if fs.NArg() < 1 {
+		stdErr.Println("no username(s) supplied")
+		stdErr.Println("try 'twitchpipe record -h' for usage information")
+		return 1
+	}
+
+	var logins []string
+	for _, username := range fs.Args() {
+		logins = append(logins, strings.ToLower(username))
+	}
+
+	client := newClient(stdErr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	shutdown := notifyShutdown(cancel)
+
+	stdErr.Println("fetching user IDs...")
+	users, err := client.GetUsers(ctx, logins)
+	if err != nil {
+		if ctx.Err() != nil {
+			return signalExitCode(<-shutdown)
+		}
+		stdErr.Printf("could not fetch user IDs: %v\n", err)
+		return 1
+	}
+
+	recorders := make(map[string]*recorder, len(users))
+	var ids []string
+	for i, user := range users {
+		if user == nil {
+			stdErr.Printf("could not get ID for %q\n", logins[i])
+			return 1
+		}
+
+		logger := log.New(os.Stderr, fmt.Sprintf("[%s] ", user.Login), 0)
+		recorderClient := *client
+		recorderClient.Logger = logger
+
+		recorders[user.ID] = &recorder{
+			client: &recorderClient,
+			log:    logger,
+			login:  user.Login,
+			id:     user.ID,
+		}
+		ids = append(ids, user.ID)
+	}
+
+	var wg sync.WaitGroup
+	check := func() {
+		users, err := client.GetUsers(ctx, logins)
+		if err != nil {
+			if ctx.Err() == nil {
+				stdErr.Printf("could not check live status: %v\n", err)
+			}
+			return
+		}
+
+		for _, user := range users {
+			if user != nil && user.Stream != nil && recorders[user.ID] != nil {
+				recorders[user.ID].start(ctx, &wg)
+			}
+		}
+	}
+
+	events := make(chan twitch.PlaybackEvent)
+	go watchPlayback(ctx, client, ids, events)
+
+	check()
+	stdErr.Println("monitoring streams...")
+
+	ticker := time.NewTicker(recordCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event := <-events:
+			if r := recorders[event.ChannelID]; r != nil && event.Type == "stream-up" {
+				r.start(ctx, &wg)
+			}
+		case <-ticker.C:
+			check()
+		case <-ctx.Done():
+			wg.Wait()
+			return signalExitCode(<-shutdown)
+		}
+	}
+}
+
+// watchPlayback keeps a PubSub connection open until ctx is cancelled,
+// reconnecting with an increasing delay whenever it fails.
+func watchPlayback(ctx context.Context, client *twitch.Client, ids []string, events chan<- twitch.PlaybackEvent) {
+	const minDelay, maxDelay = time.Second * 5, time.Minute * 2
+
+	delay := minDelay
+	for {
+		stdErr.Println("connecting to pubsub...")
+		connected := time.Now()
+		err := client.WatchPlayback(ctx, ids, events)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(connected) > maxDelay {
+			delay = minDelay
+		}
+
+		if errors.Is(err, twitch.ErrReconnect) {
+			delay = 0
+		} else {
+			stdErr.Printf("pubsub disconnected: %v, reconnecting in %v\n", err, delay)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+
+		if delay *= 2; delay < minDelay {
+			delay = minDelay
+		} else if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// recorder records the streams of a single channel, one at a time.
+type recorder struct {
+	client *twitch.Client
+	log    *log.Logger
+	login  string
+	id     string
+
+	mu     sync.Mutex
+	active bool
+
+	// last records the last segment written by the current recording, so
+	// that it can be restarted after it. It is only used by run.
+	last *resumeState
+}
+
+// start starts recording in the background unless a recording is already in progress.
+func (r *recorder) start(ctx context.Context, wg *sync.WaitGroup) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.active {
+		return
+	}
+	r.active = true
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.run(ctx)
+
+		r.mu.Lock()
+		r.active = false
+		r.mu.Unlock()
+	}()
+}
+
+// run records the current stream, restarting whenever it ends with an error.
+func (r *recorder) run(ctx context.Context) {
+	r.log.Println("stream started")
+	r.last = nil
+	for {
+		err := r.recordOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			r.log.Println("stream over")
+			return
+		}
+
+		r.log.Printf("stream ended with error, restarting: %v\n", err)
+		select {
+		case <-time.After(recordRestartDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recordOnce records the stream to a new file until the stream ends.
+// It returns nil if the channel is found to be offline.
+func (r *recorder) recordOnce(ctx context.Context) error {
+	target := target{kind: channelTarget, name: r.login}
+
+	var playlists []twitch.PlaylistInfo
+	var err error
+	for attempt := 1; ; attempt++ {
+		playlists, err = target.playlists(ctx, r.client)
+		if !errors.Is(err, twitch.ErrOffline) {
+			break
+		}
+
+		// The stream is often reported live before its playlist exists.
+		if attempt == recordLiveAttempts {
+			return nil
+		}
+
+		select {
+		case <-time.After(recordLiveInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	selected, found := selectPlaylist(playlists, groupSelect)
+	if !found {
+		return errors.New("could not find desired playlist quality")
+	}
+
+	// A restart continues after the last segment written before it,
+	// rather than downloading the segments still in the playlist again.
+	id, err := broadcastID(ctx, r.client, target)
+	if err != nil {
+		r.log.Printf("could not look up broadcast: %v\n", err)
+	}
+	var nextSeq int
+	if r.last != nil && id != "" && r.last.BroadcastID == id {
+		r.log.Printf("resuming after segment %d\n", r.last.LastSeq)
+		nextSeq = r.last.LastSeq + 1
+	}
+
+	f, err := r.create(ctx, target, selected)
+	if err != nil {
+		return err
+	}
+	if recordPrintFilenames {
+		f.done = func(name string) { fmt.Println(name) }
+	}
+	if id != "" {
+		f.ended = func(segment twitch.Segment) {
+			r.last = &resumeState{BroadcastID: id, LastSeq: segment.Seq}
+		}
+	}
+
+	var out io.Writer = f
+	if name := f.stateName(); name != "" {
+		var stateSeq int
+		out, stateSeq = resumeOutput(ctx, r.client, target, name, f, r.log)
+		if stateSeq > nextSeq {
+			nextSeq = stateSeq
+		}
+	}
+
+	pollErr, streamErr := stream(ctx, r.client, target, selected, out, true, nextSeq, nil)
+	closeErr := f.Close()
+
+	for _, err := range []error{streamErr, pollErr, closeErr} {
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create opens the output file for a recording of selected starting now.
+func (r *recorder) create(ctx context.Context, t target, selected twitch.PlaylistInfo) (*outputFile, error) {
+	if recordFilenameCommand.string == nil {
+		return createOutput(ctx, r.client, t, selected, recordOutput, r.log)
+	}
+
+	return newOutputFile(func(part int) (string, error) {
+		name, err := r.filename(ctx)
+		if err != nil {
+			return "", fmt.Errorf("could not get output filename: %w", err)
+		}
+		return partName(filepath.Join(r.login, name+".ts"), part), nil
+	}, r.log)
+}
+
+// filename runs the filename command and returns its sanitized output.
+func (r *recorder) filename(ctx context.Context) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", *recordFilenameCommand.string)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", *recordFilenameCommand.string)
+	}
+	cmd.Env = append(os.Environ(), "username="+r.login, "id="+r.id)
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	name := sanitizeComponent(safeName(strings.TrimRight(string(out), "\r\n")))
+	if name == "_" {
+		return "", errors.New("filename command returned an empty filename")
+	}
+
+	return name, nil
+}
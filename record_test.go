@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+type roundTripFunc func(req *http.Request) *http.Response
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+// twitchServer answers the requests of a twitch.Client. GQL requests get an
+// access token and the channel, live with broadcast "b1", the master playlist of the channel is answered by master,
+// and every other URL by the entry of files, or 404 if there is none.
+type twitchServer struct {
+	master func() (int, string)
+	files  map[string]string
+}
+
+func (s *twitchServer) client() *twitch.Client {
+	return &twitch.Client{HTTPClient: &http.Client{Transport: roundTripFunc(s.roundTrip)}}
+}
+
+func (s *twitchServer) roundTrip(req *http.Request) *http.Response {
+	status, body := http.StatusNotFound, ""
+	switch {
+	case req.URL.Host == "gql.twitch.tv":
+		status, body = http.StatusOK, `{"data":{"streamPlaybackAccessToken":{"value":"token","signature":"sig"},`+
+			`"users":[{"id":"1","login":"channel","stream":{"id":"b1"}}]}}`
+	case strings.HasPrefix(req.URL.Path, "/api/channel/hls/"):
+		status, body = s.master()
+	default:
+		if b, found := s.files[req.URL.String()]; found {
+			status, body = http.StatusOK, b
+		}
+	}
+
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Header:     make(http.Header),
+	}
+}
+
+func TestRecorderRun(t *testing.T) {
+	recordLiveInterval, recordRestartDelay = time.Millisecond, time.Millisecond
+	defer func() {
+		recordLiveInterval, recordRestartDelay = time.Second*10, time.Second*5
+	}()
+
+	// The first attempt fails, after which the channel is offline.
+	var requests int
+	server := &twitchServer{master: func() (int, string) {
+		requests++
+		if requests == 1 {
+			return http.StatusInternalServerError, ""
+		}
+		return http.StatusNotFound, ""
+	}}
+
+	var logged bytes.Buffer
+	r := &recorder{client: server.client(), log: log.New(&logged, "", 0), login: "channel", id: "1"}
+	r.run(context.Background())
+
+	if requests != 1+recordLiveAttempts {
+		t.Errorf("playlist was requested %d time(s), expected %d", requests, 1+recordLiveAttempts)
+	}
+	if !strings.Contains(logged.String(), "stream ended with error, restarting") || !strings.HasSuffix(logged.String(), "stream over\n") {
+		t.Errorf("recorder logged %q, expected a restart and the end of the stream", logged.String())
+	}
+}
+
+func TestRecordOnce(t *testing.T) {
+	defer func(output string, print bool) {
+		recordOutput, recordPrintFilenames = output, print
+	}(recordOutput, recordPrintFilenames)
+	recordPrintFilenames = true
+
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f *os.File) { os.Stdout = f }(os.Stdout)
+	os.Stdout = stdout
+
+	const media = "https://example.invalid/media.m3u8"
+	master := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000,CODECS=\"avc1.4D401F,mp4a.40.2\"\n" + media + "\n"
+
+	const segments = "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2.000,live\n0.ts\n#EXTINF:2.000,live\n1.ts\n#EXT-X-ENDLIST\n"
+	tests := []struct {
+		playlist string
+		// last is the last segment written before a restart.
+		last *resumeState
+		exp  string
+	}{
+		// Recordings of streams that end before any segment are removed.
+		{"#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-ENDLIST\n", nil, ""},
+		{segments, nil, "01"},
+		{segments, &resumeState{BroadcastID: "b1", LastSeq: 0}, "1"},
+		// Sequence numbers start over with every broadcast.
+		{segments, &resumeState{BroadcastID: "b0", LastSeq: 0}, "01"},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		recordOutput = filepath.Join(dir, "{channel}.ts")
+		stdout.Truncate(0)
+		stdout.Seek(0, io.SeekStart)
+
+		server := &twitchServer{
+			master: func() (int, string) { return http.StatusOK, master },
+			files: map[string]string{
+				media:                          test.playlist,
+				"https://example.invalid/0.ts": "0",
+				"https://example.invalid/1.ts": "1",
+			},
+		}
+		r := &recorder{client: server.client(), log: log.New(io.Discard, "", 0), login: "channel", id: "1", last: test.last}
+		if err := r.recordOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		name := filepath.Join(dir, "channel.ts")
+		printed, _ := os.ReadFile(stdout.Name())
+		b, err := os.ReadFile(name)
+		if test.exp == "" {
+			if !os.IsNotExist(err) || len(printed) != 0 {
+				t.Errorf("empty recording was kept (%v) or printed (%q)", err, printed)
+			}
+			continue
+		}
+
+		if string(b) != test.exp {
+			t.Errorf("recording is %q, expected %q", b, test.exp)
+		}
+		if r.last == nil || r.last.BroadcastID != "b1" || r.last.LastSeq != 1 {
+			t.Errorf("last segment written is %+v, expected segment 1 of b1", r.last)
+		}
+		if string(printed) != name+"\n" {
+			t.Errorf("printed %q, expected the name of the recording", printed)
+		}
+	}
+}
+
+func TestRecorderFilename(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	defer func(command optionalString) { recordFilenameCommand = command }(recordFilenameCommand)
+
+	tests := []struct {
+		command string
+		exp     string
+		err     bool
+	}{
+		{`printf '%s-%s\n' "$username" "$id"`, "channel-1", false},
+		{`printf 'a/b:c*d\r\n'`, "a_b_c_d", false},
+		{`printf 'title. '`, "title", false},
+		{`printf 'con'`, "_con", false},
+		{`printf '\n'`, "", true},
+		{`exit 1`, "", true},
+	}
+
+	r := &recorder{login: "channel", id: "1"}
+	for _, test := range tests {
+		command := test.command
+		recordFilenameCommand.string = &command
+
+		name, err := r.filename(context.Background())
+		if (err != nil) != test.err || name != test.exp {
+			t.Errorf("filename command %q returned %q, %v, expected %q", test.command, name, err, test.exp)
+		}
+	}
+}
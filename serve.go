@@ -0,0 +1,227 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+// viewerBuffer is the number of writes buffered for each viewer. Viewers that
+// fall further behind are disconnected rather than holding up the stream.
+const viewerBuffer = 512
+
+// viewer is a single HTTP client of a streamServer.
+type viewer struct {
+	data chan []byte
+	// remote is the address of the client, for logging.
+	remote string
+}
+
+// streamServer serves the stream it is written to as one continuous HTTP
+// response per viewer. Viewers can connect at any time and start receiving
+// at the next segment boundary, preceded by the initialization section if
+// the stream is fragmented MP4.
+type streamServer struct {
+	logger *log.Logger
+
+	mu sync.Mutex
+	// active viewers receive everything written.
+	active map[*viewer]bool
+	// waiting viewers start at the next segment.
+	waiting []*viewer
+	// starting viewers start with the current segment.
+	starting []*viewer
+	closed   bool
+
+	init initCache
+}
+
+func newStreamServer(logger *log.Logger) *streamServer {
+	return &streamServer{
+		logger: logger,
+		active: make(map[*viewer]bool),
+	}
+}
+
+func (s *streamServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v := &viewer{data: make(chan []byte, viewerBuffer), remote: r.RemoteAddr}
+	if !s.add(v) {
+		http.Error(w, "stream over", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.remove(v)
+
+	s.logger.Printf("viewer %s connected\n", v.remote)
+	defer s.logger.Printf("viewer %s disconnected\n", v.remote)
+
+	var started bool
+	for {
+		select {
+		case data, ok := <-v.data:
+			if !ok {
+				return
+			}
+
+			if !started {
+				w.Header().Set("Content-Type", s.contentType())
+				w.Header().Set("Cache-Control", "no-cache")
+				started = true
+			}
+
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// add registers v to start at the next segment. It returns false once the
+// server is closed.
+func (s *streamServer) add(v *viewer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.waiting = append(s.waiting, v)
+	return true
+}
+
+// remove unregisters v, closing its channel if that has not happened yet.
+func (s *streamServer) remove(v *viewer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drop(v)
+}
+
+// drop unregisters v and closes its channel. s.mu must be held.
+func (s *streamServer) drop(v *viewer) {
+	found := s.active[v]
+	delete(s.active, v)
+	for _, list := range []*[]*viewer{&s.waiting, &s.starting} {
+		for i, w := range *list {
+			if w == v {
+				*list = append((*list)[:i], (*list)[i+1:]...)
+				found = true
+				break
+			}
+		}
+	}
+
+	if found {
+		close(v.data)
+	}
+}
+
+func (s *streamServer) contentType() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.init.mapURI != "" {
+		return "video/mp4"
+	}
+	return "video/mp2t"
+}
+
+// BeginSegment implements twitch.SegmentWriter, letting waiting viewers start.
+func (s *streamServer) BeginSegment(segment twitch.Segment) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.starting = append(s.starting, s.waiting...)
+	s.waiting = nil
+	s.init.begin(segment)
+
+	return false, nil
+}
+
+// EndSegment implements twitch.SegmentWriter.
+func (s *streamServer) EndSegment(segment twitch.Segment) error {
+	return nil
+}
+
+// WriteInit implements twitch.InitWriter, keeping the initialization section
+// for viewers that start later.
+func (s *streamServer) WriteInit(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.init.write(p)
+
+	s.start(nil)
+	s.broadcast(p)
+	return len(p), nil
+}
+
+func (s *streamServer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.start(s.init.replay())
+	s.broadcast(p)
+	return len(p), nil
+}
+
+// start makes starting viewers active, first sending them init if set.
+// s.mu must be held.
+func (s *streamServer) start(init []byte) {
+	starting := s.starting
+	s.starting = nil
+	for _, v := range starting {
+		s.active[v] = true
+		if init != nil {
+			s.send(v, init)
+		}
+	}
+}
+
+// broadcast sends p to every active viewer. s.mu must be held.
+func (s *streamServer) broadcast(p []byte) {
+	if len(s.active) == 0 {
+		return
+	}
+
+	// p is only valid until Write returns.
+	data := append([]byte(nil), p...)
+	for v := range s.active {
+		s.send(v, data)
+	}
+}
+
+// send queues data for v, disconnecting v if it has fallen too far behind.
+// s.mu must be held.
+func (s *streamServer) send(v *viewer, data []byte) {
+	select {
+	case v.data <- data:
+	default:
+		s.logger.Printf("viewer %s is too slow, disconnecting\n", v.remote)
+		s.drop(v)
+	}
+}
+
+// Close ends the responses of all viewers and refuses new ones.
+func (s *streamServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for v := range s.active {
+		s.drop(v)
+	}
+	for len(s.waiting) > 0 {
+		s.drop(s.waiting[0])
+	}
+	for len(s.starting) > 0 {
+		s.drop(s.starting[0])
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+func TestStreamServer(t *testing.T) {
+	server := newStreamServer(log.New(io.Discard, "", 0))
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	type result struct {
+		body        string
+		contentType string
+	}
+
+	// connect starts a viewer and waits until the server has registered it.
+	connect := func() <-chan result {
+		done := make(chan result, 1)
+		go func() {
+			res, err := http.Get(ts.URL)
+			if err != nil {
+				t.Error(err)
+				done <- result{}
+				return
+			}
+			defer res.Body.Close()
+
+			body, _ := io.ReadAll(res.Body)
+			done <- result{string(body), res.Header.Get("Content-Type")}
+		}()
+
+		for deadline := time.Now().Add(time.Second * 5); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			server.mu.Lock()
+			waiting := len(server.waiting)
+			server.mu.Unlock()
+			if waiting > 0 {
+				return done
+			}
+		}
+		t.Fatal("viewer did not connect")
+		return nil
+	}
+
+	segment := twitch.Segment{MapURI: "https://example.invalid/init.mp4"}
+
+	first := connect()
+	server.BeginSegment(segment)
+	server.WriteInit([]byte("I"))
+	server.Write([]byte("1"))
+
+	// The second viewer starts at the next segment, with the initialization
+	// section it missed.
+	second := connect()
+	server.Write([]byte("x"))
+	server.BeginSegment(segment)
+	server.Write([]byte("2"))
+
+	server.Close()
+
+	for _, test := range []struct {
+		res <-chan result
+		exp string
+	}{
+		{first, "I1x2"},
+		{second, "I2"},
+	} {
+		res := <-test.res
+		if res.body != test.exp {
+			t.Errorf("viewer received %q, expected %q", res.body, test.exp)
+		}
+		if res.contentType != "video/mp4" {
+			t.Errorf("viewer received content type %q, expected %q", res.contentType, "video/mp4")
+		}
+	}
+}
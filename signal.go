@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyShutdown calls cancel on the first SIGINT or SIGTERM and returns a
+// channel that receives that signal. A second signal exits immediately.
+func notifyShutdown(cancel context.CancelFunc) <-chan os.Signal {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	received := make(chan os.Signal, 1)
+	go func() {
+		sig := <-sigs
+		stdErr.Printf("received %v, stopping after the current segment...\n", sig)
+		received <- sig
+		cancel()
+
+		sig = <-sigs
+		stdErr.Printf("received %v again, exiting immediately\n", sig)
+		os.Exit(signalExitCode(sig))
+	}()
+
+	return received
+}
+
+// ignoreBrokenPipe makes writing to a closed standard output fail with an
+// error rather than terminate the process.
+func ignoreBrokenPipe() {
+	signal.Ignore(syscall.SIGPIPE)
+}
+
+// signalExitCode returns the conventional shell exit status for a process
+// terminated by sig.
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
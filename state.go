@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+// resumeState is the content of a state file, which records the last segment
+// written to an output so that a later run appending to it can resume after it.
+type resumeState struct {
+	Target string `json:"target"`
+	// BroadcastID identifies the broadcast, since media sequence numbers
+	// start over with every broadcast. For videos it is the video ID.
+	BroadcastID string    `json:"broadcast_id"`
+	LastSeq     int       `json:"last_seq"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// readState reads the state file name. It returns nil if the file does not exist.
+func readState(name string) (*resumeState, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s resumeState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("could not decode state file: %w", err)
+	}
+
+	return &s, nil
+}
+
+// write replaces the state file name with s.
+// The file is replaced atomically, so a crash cannot leave it truncated.
+func (s *resumeState) write(name string) error {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// broadcastID returns the ID of the current broadcast of t.
+func broadcastID(ctx context.Context, client *twitch.Client, t target) (string, error) {
+	switch t.kind {
+	case videoTarget:
+		return t.name, nil
+	case channelTarget:
+		users, err := client.GetUsers(ctx, []string{t.name})
+		if err != nil {
+			return "", err
+		}
+		if users[0] == nil || users[0].Stream == nil {
+			return "", twitch.ErrOffline
+		}
+		return users[0].Stream.ID, nil
+	}
+	return "", errors.New("clips cannot be resumed")
+}
+
+// stateWriter passes writes through to an output and records the last segment
+// written to it completely in a state file.
+type stateWriter struct {
+	io.Writer
+	name   string
+	logger *log.Logger
+	state  resumeState
+}
+
+// resumeOutput wraps out in a stateWriter for the state file name. If the
+// state file is for the current broadcast of t, it also returns the media
+// sequence number to resume from, or 0 to start from the beginning.
+func resumeOutput(ctx context.Context, client *twitch.Client, t target, name string, out io.Writer, logger *log.Logger) (*stateWriter, int) {
+	w := &stateWriter{
+		Writer: out,
+		name:   name,
+		logger: logger,
+		state:  resumeState{Target: t.String()},
+	}
+
+	id, err := broadcastID(ctx, client, t)
+	if err != nil {
+		logger.Printf("could not look up broadcast, not resuming: %v\n", err)
+		return w, 0
+	}
+	w.state.BroadcastID = id
+
+	prev, err := readState(name)
+	if err != nil {
+		logger.Printf("could not read state file %s, not resuming: %v\n", name, err)
+		return w, 0
+	}
+	if prev == nil {
+		return w, 0
+	}
+
+	if prev.Target != w.state.Target || prev.BroadcastID != id {
+		logger.Printf("state file %s is for another broadcast, not resuming\n", name)
+		return w, 0
+	}
+
+	logger.Printf("resuming after segment %d\n", prev.LastSeq)
+	w.state.LastSeq = prev.LastSeq
+	return w, prev.LastSeq + 1
+}
+
+// BeginSegment implements twitch.SegmentWriter.
+func (w *stateWriter) BeginSegment(segment twitch.Segment) (bool, error) {
+	if sw, ok := w.Writer.(twitch.SegmentWriter); ok {
+		return sw.BeginSegment(segment)
+	}
+	return false, nil
+}
+
+// EndSegment implements twitch.SegmentWriter, recording segment as written.
+// Failing to update the state file is logged rather than interrupting the stream.
+func (w *stateWriter) EndSegment(segment twitch.Segment) error {
+	if sw, ok := w.Writer.(twitch.SegmentWriter); ok {
+		if err := sw.EndSegment(segment); err != nil {
+			return err
+		}
+	}
+
+	w.state.LastSeq = segment.Seq
+	w.state.UpdatedAt = time.Now()
+	if err := w.state.write(w.name); err != nil {
+		w.logger.Printf("could not update state file: %v\n", err)
+	}
+
+	return nil
+}
+
+// WriteInit implements twitch.InitWriter, passing initialization sections on
+// to the output if it needs to tell them apart.
+func (w *stateWriter) WriteInit(p []byte) (int, error) {
+	if iw, ok := w.Writer.(twitch.InitWriter); ok {
+		return iw.WriteInit(p)
+	}
+	return w.Writer.Write(p)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+func TestResumeOutput(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "out.ts.state")
+	logger := log.New(io.Discard, "", 0)
+	video := target{videoTarget, "123456"}
+
+	var out bytes.Buffer
+	w, nextSeq := resumeOutput(context.Background(), nil, video, name, &out, logger)
+	if nextSeq != 0 {
+		t.Errorf("resumeOutput without state file returned %d, expected 0", nextSeq)
+	}
+
+	for _, seq := range []int{5, 6} {
+		segment := twitch.Segment{Seq: seq}
+		if _, err := w.BeginSegment(segment); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("data"))
+
+		// Segments only count once they are written completely.
+		if _, nextSeq = resumeOutput(context.Background(), nil, video, name, &out, logger); seq == 6 && nextSeq != 6 {
+			t.Errorf("resumeOutput before EndSegment returned %d, expected 6", nextSeq)
+		}
+
+		if err := w.EndSegment(segment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, nextSeq = resumeOutput(context.Background(), nil, video, name, &out, logger); nextSeq != 7 {
+		t.Errorf("resumeOutput after EndSegment returned %d, expected 7", nextSeq)
+	}
+
+	if _, nextSeq = resumeOutput(context.Background(), nil, target{videoTarget, "654321"}, name, &out, logger); nextSeq != 0 {
+		t.Errorf("resumeOutput for another video returned %d, expected 0", nextSeq)
+	}
+
+	if out.String() != "datadata" {
+		t.Errorf("output is %q, expected %q", out.String(), "datadata")
+	}
+}
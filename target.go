@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+type targetKind int
+
+const (
+	channelTarget targetKind = iota
+	videoTarget
+	clipTarget
+)
+
+// target is the channel, video or clip to stream.
+type target struct {
+	kind targetKind
+	// name is the channel's username, the video's ID or the clip's slug.
+	name string
+}
+
+func (t target) String() string {
+	switch t.kind {
+	case videoTarget:
+		return "video " + t.name
+	case clipTarget:
+		return "clip " + t.name
+	}
+	return t.name
+}
+
+// parseTarget parses the USERNAME argument. If isURL is set, arg is a Twitch
+// URL from which the channel, video or clip is extracted. Otherwise arg is a
+// video ID if isVideo is set, or a username.
+func parseTarget(arg string, isURL, isVideo bool) (target, error) {
+	t := target{name: arg}
+	if isVideo {
+		t.kind = videoTarget
+	}
+
+	if isURL {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return t, fmt.Errorf("could not parse username as URL: %w", err)
+		}
+
+		path := strings.Split(strings.Trim(u.Path, "/"), "/")
+		switch {
+		case u.Host == "clips.twitch.tv":
+			t = target{kind: clipTarget, name: path[0]}
+		case len(path) >= 2 && path[0] == "clip":
+			t = target{kind: clipTarget, name: path[1]}
+		case len(path) >= 3 && path[1] == "clip":
+			t = target{kind: clipTarget, name: path[2]}
+		case len(path) >= 2 && path[0] == "videos":
+			t = target{kind: videoTarget, name: path[1]}
+		default:
+			t.name = path[0]
+		}
+	}
+
+	if t.name == "" {
+		return t, errors.New("no username specified")
+	}
+
+	if t.kind == videoTarget {
+		t.name = strings.TrimPrefix(t.name, "v")
+		if strings.Trim(t.name, "0123456789") != "" {
+			return t, fmt.Errorf("invalid video ID %q", t.name)
+		}
+	} else if t.kind == channelTarget {
+		t.name = strings.ToLower(t.name)
+	}
+
+	return t, nil
+}
+
+// playlists acquires an access token for t and returns the variants of its
+// master playlist. The variants of a clip each point to a single file.
+func (t target) playlists(ctx context.Context, client *twitch.Client) ([]twitch.PlaylistInfo, error) {
+	if t.kind == clipTarget {
+		clip, err := client.GetClip(ctx, t.name)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch clip: %w", err)
+		}
+		return clip.Playlists(), nil
+	}
+
+	var token *twitch.AccessToken
+	var err error
+	if t.kind == videoTarget {
+		token, err = client.GetVideoAccessToken(ctx, t.name)
+	} else {
+		token, err = client.GetAccessToken(ctx, t.name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not acquire access token: %w", err)
+	}
+
+	var playlists []twitch.PlaylistInfo
+	if t.kind == videoTarget {
+		playlists, err = client.GetVideoPlaylists(ctx, t.name, token)
+	} else {
+		playlists, err = client.GetPlaylists(ctx, t.name, token)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not extract playlist: %w", err)
+	}
+
+	return playlists, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		arg     string
+		isURL   bool
+		isVideo bool
+		exp     target
+		err     bool
+	}{
+		{"UserName", false, false, target{channelTarget, "username"}, false},
+		{"123456", false, true, target{videoTarget, "123456"}, false},
+		{"v123456", false, true, target{videoTarget, "123456"}, false},
+		{"abc", false, true, target{}, true},
+		{"https://www.twitch.tv/UserName", true, false, target{channelTarget, "username"}, false},
+		{"https://www.twitch.tv/username/videos", true, false, target{channelTarget, "username"}, false},
+		{"https://www.twitch.tv/videos/123456?t=1h", true, false, target{videoTarget, "123456"}, false},
+		{"https://clips.twitch.tv/FunnySlug-abc_123", true, false, target{clipTarget, "FunnySlug-abc_123"}, false},
+		{"https://www.twitch.tv/username/clip/FunnySlug?filter=clips", true, false, target{clipTarget, "FunnySlug"}, false},
+		{"https://m.twitch.tv/clip/FunnySlug", true, false, target{clipTarget, "FunnySlug"}, false},
+		{"https://www.twitch.tv/", true, false, target{}, true},
+		{"", false, false, target{}, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseTarget(test.arg, test.isURL, test.isVideo)
+		if test.err {
+			if err == nil {
+				t.Errorf("parseTarget(%q): expected error, got %v", test.arg, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTarget(%q): unexpected error: %v", test.arg, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.exp, got) {
+			t.Errorf("parseTarget(%q) = %#v, expected %#v", test.arg, got, test.exp)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+const (
+	// sinkFailureStop ends the stream when the output fails.
+	sinkFailureStop = "stop"
+	// sinkFailureContinue carries on without the output when it fails,
+	// as long as any other output is left.
+	sinkFailureContinue = "continue"
+)
+
+// sinkNames are the names of the outputs a tee can write to, used to set
+// their failure policies.
+// COMMAND is handled by --command-exit instead.
+var sinkNames = []string{"stdout", "output", "serve", "hls"}
+
+// parseSinkFailure parses an --output-failure value: a comma-separated list of
+// policies, each either for all outputs or for one output as NAME=POLICY.
+// Later entries override earlier ones, outputs without a policy continue.
+func parseSinkFailure(s string) (map[string]string, error) {
+	policies := make(map[string]string)
+	for _, n := range sinkNames {
+		policies[n] = sinkFailureContinue
+	}
+	for _, item := range strings.Split(s, ",") {
+		name, policy, found := strings.Cut(strings.TrimSpace(item), "=")
+		if !found {
+			name, policy = "", name
+		}
+
+		if policy != sinkFailureStop && policy != sinkFailureContinue {
+			return nil, fmt.Errorf("invalid output failure policy %q", policy)
+		}
+
+		if name == "" {
+			for _, n := range sinkNames {
+				policies[n] = policy
+			}
+			continue
+		}
+
+		known := false
+		for _, n := range sinkNames {
+			known = known || n == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown output %q, expected one of %s", name, strings.Join(sinkNames, ", "))
+		}
+		policies[name] = policy
+	}
+
+	return policies, nil
+}
+
+// sinkEventKind is the call on a tee that a sinkEvent replays.
+type sinkEventKind int
+
+const (
+	beginEvent sinkEventKind = iota
+	initEvent
+	dataEvent
+	endEvent
+)
+
+type sinkEvent struct {
+	kind    sinkEventKind
+	segment twitch.Segment
+	data    []byte
+}
+
+// initCache keeps the last initialization section written to an output,
+// so that it can be repeated for segments that do not bring their own.
+type initCache struct {
+	// mapURI is the URI of the initialization section of the current segment.
+	mapURI string
+	// data is the last initialization section written, uri its URI.
+	data []byte
+	uri  string
+	// written is set once the initialization section of the current
+	// segment starts being written.
+	written bool
+}
+
+// begin is called before segment is written.
+func (c *initCache) begin(segment twitch.Segment) {
+	c.mapURI, c.written = segment.MapURI, false
+}
+
+// write adds p to the initialization section of the current segment.
+func (c *initCache) write(p []byte) {
+	if !c.written {
+		c.data, c.uri = nil, c.mapURI
+		c.written = true
+	}
+	c.data = append(c.data, p...)
+}
+
+// replay returns the initialization section to repeat before the current
+// segment, or nil if the segment brought its own or none is known for it.
+func (c *initCache) replay() []byte {
+	if c.written || c.mapURI == "" || c.mapURI != c.uri {
+		return nil
+	}
+	return c.data
+}
+
+// sink is an output of a tee. Writes are queued and passed on to the output
+// by a goroutine of its own, so that a slow output only holds up the others
+// once its buffer is full.
+type sink struct {
+	name   string
+	w      io.Writer
+	policy string
+	// limit is the number of bytes that can be queued.
+	limit int64
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []sinkEvent
+	buffered int64
+	closed   bool
+	// err is the error the output failed with.
+	err  error
+	done chan struct{}
+
+	// The fields below are only used by the sink's goroutine.
+
+	// reinit is set if the output asked for the initialization section
+	// before the current segment.
+	reinit bool
+	init   initCache
+}
+
+// send queues e, waiting for room in the buffer if necessary.
+// It returns the error the output failed with, if any.
+func (s *sink) send(e sinkEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.err == nil && s.buffered > 0 && s.buffered+int64(len(e.data)) > s.limit {
+		s.cond.Wait()
+	}
+	if s.err != nil {
+		return s.err
+	}
+
+	s.queue = append(s.queue, e)
+	s.buffered += int64(len(e.data))
+	s.cond.Broadcast()
+	return nil
+}
+
+// failed returns the error the output failed with, if any.
+func (s *sink) failed() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// close stops s once everything queued has been written and returns the
+// error the output failed with, if any.
+func (s *sink) close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	<-s.done
+	return s.failed()
+}
+
+func (s *sink) run() {
+	defer close(s.done)
+
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		e := s.queue[0]
+		s.queue = s.queue[1:]
+		s.buffered -= int64(len(e.data))
+		s.cond.Broadcast()
+		s.mu.Unlock()
+
+		if err := s.apply(e); err != nil {
+			s.mu.Lock()
+			s.err = fmt.Errorf("%s: %w", s.name, err)
+			s.queue, s.buffered = nil, 0
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// apply passes e on to the output.
+func (s *sink) apply(e sinkEvent) error {
+	sw, _ := s.w.(twitch.SegmentWriter)
+
+	switch e.kind {
+	case beginEvent:
+		s.init.begin(e.segment)
+		s.reinit = false
+		if sw == nil {
+			return nil
+		}
+		reinit, err := sw.BeginSegment(e.segment)
+		s.reinit = reinit
+		return err
+	case initEvent:
+		s.init.write(e.data)
+		// The segment brings its own initialization section.
+		s.reinit = false
+		return s.writeInit(e.data)
+	case dataEvent:
+		if s.reinit {
+			s.reinit = false
+			if init := s.init.replay(); init != nil {
+				if err := s.writeInit(init); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := s.w.Write(e.data)
+		return err
+	case endEvent:
+		if sw == nil {
+			return nil
+		}
+		return sw.EndSegment(e.segment)
+	}
+
+	return nil
+}
+
+func (s *sink) writeInit(p []byte) error {
+	if iw, ok := s.w.(twitch.InitWriter); ok {
+		_, err := iw.WriteInit(p)
+		return err
+	}
+	_, err := s.w.Write(p)
+	return err
+}
+
+// tee writes a stream to several outputs, each buffered separately and with
+// its own policy for when it fails. Outputs that ask for the initialization
+// section to be repeated get the last one the tee has seen.
+type tee struct {
+	logger *log.Logger
+	limit  int64
+
+	mu    sync.Mutex
+	sinks []*sink
+}
+
+// newTee returns a tee buffering up to limit bytes per output.
+func newTee(limit int64, logger *log.Logger) *tee {
+	return &tee{logger: logger, limit: limit}
+}
+
+// add starts writing the stream to w from the next write on.
+func (t *tee) add(name string, w io.Writer, policy string) {
+	s := &sink{
+		name:   name,
+		w:      w,
+		policy: policy,
+		limit:  t.limit,
+		done:   make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	go s.run()
+
+	t.mu.Lock()
+	t.sinks = append(t.sinks, s)
+	t.mu.Unlock()
+}
+
+// send queues e for every output. Outputs that failed are dropped if their
+// policy allows it, otherwise their error is returned.
+func (t *tee) send(e sinkEvent) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var lastErr error
+	var sinks []*sink
+	for _, s := range t.sinks {
+		err := s.failed()
+		if err == nil {
+			err = s.send(e)
+		}
+		if err == nil {
+			sinks = append(sinks, s)
+			continue
+		}
+
+		if s.policy != sinkFailureContinue {
+			return err
+		}
+		t.logger.Printf("%v, continuing without it\n", err)
+		lastErr = err
+	}
+	t.sinks = sinks
+
+	if len(t.sinks) == 0 {
+		if lastErr == nil {
+			lastErr = errors.New("no outputs")
+		}
+		return fmt.Errorf("every output failed, last: %w", lastErr)
+	}
+
+	return nil
+}
+
+func (t *tee) Write(p []byte) (int, error) {
+	// p is only valid until Write returns.
+	if err := t.send(sinkEvent{kind: dataEvent, data: append([]byte(nil), p...)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteInit implements twitch.InitWriter.
+func (t *tee) WriteInit(p []byte) (int, error) {
+	if err := t.send(sinkEvent{kind: initEvent, data: append([]byte(nil), p...)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// BeginSegment implements twitch.SegmentWriter. Outputs that need the
+// initialization section repeated are handled by the tee itself.
+func (t *tee) BeginSegment(segment twitch.Segment) (bool, error) {
+	return false, t.send(sinkEvent{kind: beginEvent, segment: segment})
+}
+
+// EndSegment implements twitch.SegmentWriter.
+func (t *tee) EndSegment(segment twitch.Segment) error {
+	return t.send(sinkEvent{kind: endEvent, segment: segment})
+}
+
+// Close waits for everything queued to be written and returns the first error
+// of an output that failed.
+func (t *tee) Close() error {
+	t.mu.Lock()
+	sinks := t.sinks
+	t.sinks = nil
+	t.mu.Unlock()
+
+	var err error
+	for _, s := range sinks {
+		sErr := s.close()
+		if sErr != nil && s.policy == sinkFailureContinue {
+			t.logger.Printf("%v\n", sErr)
+		} else if sErr != nil && err == nil {
+			err = sErr
+		}
+	}
+	return err
+}
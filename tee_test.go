@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+// failingWriter fails every write after the first n.
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("closed")
+	}
+	w.n--
+	return len(p), nil
+}
+
+// reinitWriter asks for the initialization section before every segment.
+type reinitWriter struct {
+	bytes.Buffer
+}
+
+func (w *reinitWriter) BeginSegment(segment twitch.Segment) (bool, error) {
+	w.WriteString("|")
+	return true, nil
+}
+
+func (w *reinitWriter) EndSegment(segment twitch.Segment) error {
+	return nil
+}
+
+// writeSegments writes segments as the twitch package would, the first
+// preceded by its initialization section.
+func writeSegments(out *tee, segments ...string) error {
+	for i, s := range segments {
+		segment := twitch.Segment{Seq: i, MapURI: "init.mp4"}
+		if _, err := out.BeginSegment(segment); err != nil {
+			return err
+		}
+		if i == 0 {
+			if _, err := out.WriteInit([]byte("init")); err != nil {
+				return err
+			}
+		}
+		if _, err := out.Write([]byte(s)); err != nil {
+			return err
+		}
+		if err := out.EndSegment(segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestTee(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+
+	var plain bytes.Buffer
+	reinit := &reinitWriter{}
+	out := newTee(4, logger)
+	out.add("stdout", &plain, sinkFailureStop)
+	out.add("output", reinit, sinkFailureStop)
+	out.add("command", &failingWriter{n: 2}, sinkFailureContinue)
+
+	if err := writeSegments(out, "0", "1", "2", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := "init0123"; plain.String() != exp {
+		t.Errorf("plain output is %q, expected %q", plain.String(), exp)
+	}
+	if exp := "|init0|init1|init2|init3"; reinit.String() != exp {
+		t.Errorf("output asking for reinit is %q, expected %q", reinit.String(), exp)
+	}
+
+	out = newTee(4, logger)
+	out.add("command", &failingWriter{n: 1}, sinkFailureStop)
+	err := writeSegments(out, "0", "1", "2", "3")
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		t.Error("failure of output with stop policy was not returned")
+	}
+}
+
+func TestParseSinkFailure(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp map[string]string
+		err bool
+	}{
+		{"stop", map[string]string{"stdout": "stop", "output": "stop", "serve": "stop", "hls": "stop"}, false},
+		{"continue,output=stop", map[string]string{"stdout": "continue", "output": "stop", "serve": "continue", "hls": "continue"}, false},
+		{"serve=stop", map[string]string{"stdout": "continue", "output": "continue", "serve": "stop", "hls": "continue"}, false},
+		{"command=stop", nil, true},
+		{"ignore", nil, true},
+		{"player=stop", nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := parseSinkFailure(test.s)
+		if (err != nil) != test.err {
+			t.Errorf("parseSinkFailure(%q) returned error %v", test.s, err)
+			continue
+		}
+		if !test.err && !reflect.DeepEqual(got, test.exp) {
+			t.Errorf("parseSinkFailure(%q) = %v, expected %v", test.s, got, test.exp)
+		}
+	}
+}
+
+func TestInitCache(t *testing.T) {
+	var c initCache
+
+	c.begin(twitch.Segment{MapURI: "init.mp4"})
+	if init := c.replay(); init != nil {
+		t.Errorf("replayed %q before any initialization section was written", init)
+	}
+	c.write([]byte("IN"))
+	c.write([]byte("IT"))
+	if init := c.replay(); init != nil {
+		t.Errorf("replayed %q for the segment that wrote it", init)
+	}
+
+	c.begin(twitch.Segment{MapURI: "init.mp4"})
+	if init := c.replay(); string(init) != "INIT" {
+		t.Errorf("replayed %q, expected %q", init, "INIT")
+	}
+
+	// A different initialization section is needed after a discontinuity.
+	c.begin(twitch.Segment{MapURI: "init2.mp4"})
+	if init := c.replay(); init != nil {
+		t.Errorf("replayed %q for a different initialization section", init)
+	}
+}
@@ -0,0 +1,67 @@
+package twitch
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+)
+
+// AccessToken is a signed playback token used to request playlists.
+type AccessToken struct {
+	Value     string `json:"value"`
+	Signature string `json:"signature"`
+}
+
+type gqlAccessToken struct {
+	StreamPlaybackAccessToken struct {
+		AccessToken
+	} `json:"streamPlaybackAccessToken"`
+	VideoPlaybackAccessToken *struct {
+		AccessToken
+	} `json:"videoPlaybackAccessToken"`
+}
+
+var (
+	//go:embed access_token.gql
+	accessTokenQuery string
+	//go:embed video_access_token.gql
+	videoAccessTokenQuery string
+)
+
+// ErrVideoNotFound is returned when a video does not exist or is not accessible.
+var ErrVideoNotFound = errors.New("video not found")
+
+// tokenVariables returns c.TokenVariables with the given variable added,
+// leaving c.TokenVariables untouched.
+func (c *Client) tokenVariables(key string, value any) map[string]any {
+	variables := make(map[string]any, len(c.TokenVariables)+1)
+	for k, v := range c.TokenVariables {
+		variables[k] = v
+	}
+	variables[key] = value
+	return variables
+}
+
+// GetAccessToken acquires a playback access token for the live stream of channelName.
+func (c *Client) GetAccessToken(ctx context.Context, channelName string) (*AccessToken, error) {
+	var accessToken gqlAccessToken
+	if err := c.gql(ctx, accessTokenQuery, c.tokenVariables("channelName", channelName), &accessToken, "access token"); err != nil {
+		return nil, err
+	}
+
+	return &accessToken.StreamPlaybackAccessToken.AccessToken, nil
+}
+
+// GetVideoAccessToken acquires a playback access token for the video (VOD) with the given ID.
+func (c *Client) GetVideoAccessToken(ctx context.Context, id string) (*AccessToken, error) {
+	var accessToken gqlAccessToken
+	if err := c.gql(ctx, videoAccessTokenQuery, c.tokenVariables("id", id), &accessToken, "access token"); err != nil {
+		return nil, err
+	}
+
+	if accessToken.VideoPlaybackAccessToken == nil {
+		return nil, ErrVideoNotFound
+	}
+
+	return &accessToken.VideoPlaybackAccessToken.AccessToken, nil
+}
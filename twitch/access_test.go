@@ -0,0 +1,106 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestGetAccessToken(t *testing.T) {
+	testUsername := "testing"
+	testOAuth := "secret"
+	testDeviceID := "abc123"
+	accessToken := AccessToken{
+		Value:     "token",
+		Signature: "sig",
+	}
+
+	gqlVariables := map[string]any{
+		"testing": "123",
+		"hello":   "world",
+	}
+
+	var gqlat gqlAccessToken
+	gqlat.StreamPlaybackAccessToken.AccessToken = accessToken
+
+	var gqlrd bytes.Buffer
+	ok(t, json.NewEncoder(&gqlrd).Encode(&gqlat))
+
+	gqlr := gqlResponse{
+		Data: gqlrd.Bytes(),
+	}
+
+	httpClient := NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, gqlURL, req.URL.String())
+		equals(t, http.MethodPost, req.Method)
+		equals(t, clientID, req.Header.Get("Client-ID"))
+		equals(t, "OAuth "+testOAuth, req.Header.Get("Authorization"))
+		equals(t, testDeviceID, req.Header.Get("Device-ID"))
+
+		var gqlq gqlQuery
+		ok(t, json.NewDecoder(req.Body).Decode(&gqlq))
+
+		equals(t, testUsername, gqlq.Variables["channelName"])
+		for k := range gqlVariables {
+			equals(t, gqlVariables[k], gqlq.Variables[k])
+		}
+
+		var jsonBuf bytes.Buffer
+		ok(t, json.NewEncoder(&jsonBuf).Encode(&gqlr))
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(&jsonBuf),
+			Header:     make(http.Header),
+		}
+	})
+
+	client := &Client{
+		HTTPClient:     httpClient,
+		OAuthToken:     &testOAuth,
+		DeviceID:       &testDeviceID,
+		TokenVariables: gqlVariables,
+	}
+
+	testToken, err := client.GetAccessToken(context.Background(), testUsername)
+	ok(t, err)
+	equals(t, &accessToken, testToken)
+	_, mutated := gqlVariables["channelName"]
+	equals(t, false, mutated)
+}
+
+func TestGetVideoAccessToken(t *testing.T) {
+	accessToken := AccessToken{
+		Value:     "token",
+		Signature: "sig",
+	}
+
+	for _, found := range []bool{true, false} {
+		client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+			var gqlq gqlQuery
+			ok(t, json.NewDecoder(req.Body).Decode(&gqlq))
+			equals(t, "123456", gqlq.Variables["id"])
+
+			data := `{"videoPlaybackAccessToken":null}`
+			if found {
+				data = `{"videoPlaybackAccessToken":{"value":"token","signature":"sig"}}`
+			}
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"data":` + data + `}`)),
+				Header:     make(http.Header),
+			}
+		})}
+
+		testToken, err := client.GetVideoAccessToken(context.Background(), "123456")
+		if !found {
+			equals(t, ErrVideoNotFound, err)
+			continue
+		}
+		ok(t, err)
+		equals(t, &accessToken, testToken)
+	}
+}
@@ -0,0 +1,81 @@
+package twitch
+
+import "strings"
+
+// attribute is a single AttributeName=AttributeValue pair of an attribute list.
+// Quoted string values are stored without their quotes.
+type attribute struct {
+	Key    string
+	Value  string
+	Quoted bool
+}
+
+type attributeList []attribute
+
+// parseAttributes parses a comma separated attribute list as described in
+// RFC 8216 section 4.2. Malformed pairs are skipped.
+func parseAttributes(s string) attributeList {
+	var attrs attributeList
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+
+		// A comma before the '=' ends a malformed pair without a value.
+		if comma := strings.IndexByte(s[:eq], ','); comma >= 0 {
+			s = s[comma+1:]
+			continue
+		}
+
+		attr := attribute{Key: strings.TrimSpace(s[:eq])}
+		s = s[eq+1:]
+
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				break
+			}
+			attr.Value, attr.Quoted = s[1:end+1], true
+			s = s[end+2:]
+		}
+
+		comma := strings.IndexByte(s, ',')
+		if comma < 0 {
+			comma = len(s)
+		}
+		if !attr.Quoted {
+			attr.Value = strings.TrimSpace(s[:comma])
+		}
+		if comma < len(s) {
+			comma++
+		}
+		s = s[comma:]
+
+		if attr.Key != "" && !strings.ContainsRune(attr.Key, '"') {
+			attrs = append(attrs, attr)
+		}
+	}
+
+	return attrs
+}
+
+// Get returns the value of the attribute named key, if present.
+func (a attributeList) Get(key string) (string, bool) {
+	for _, attr := range a {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// Map returns the attributes as a map of names to values. Later attributes
+// replace earlier ones of the same name.
+func (a attributeList) Map() map[string]string {
+	m := make(map[string]string, len(a))
+	for _, attr := range a {
+		m[attr.Key] = attr.Value
+	}
+	return m
+}
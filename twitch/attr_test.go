@@ -0,0 +1,80 @@
+package twitch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAttributes(t *testing.T) {
+	attrs := parseAttributes(`ID="a,b",DURATION=15.000, CLASS="twitch-stitched-ad",EMPTY=""`)
+	equals(t, attributeList{
+		{Key: "ID", Value: "a,b", Quoted: true},
+		{Key: "DURATION", Value: "15.000"},
+		{Key: "CLASS", Value: "twitch-stitched-ad", Quoted: true},
+		{Key: "EMPTY", Value: "", Quoted: true},
+	}, attrs)
+
+	v, found := attrs.Get("CLASS")
+	equals(t, true, found)
+	equals(t, "twitch-stitched-ad", v)
+
+	_, found = attrs.Get("MISSING")
+	equals(t, false, found)
+}
+
+func TestParseAttributesMalformed(t *testing.T) {
+	equals(t, attributeList{
+		{Key: "B", Value: "1"},
+		{Key: "D", Value: "x\"y"},
+		{Key: "E", Value: ""},
+	}, parseAttributes(`A,B=1,"C"=2,D=x"y,E=`))
+	equals(t, attributeList(nil), parseAttributes(`A="unterminated`))
+}
+
+// FuzzParseAttributes checks that attribute lists written from the parsed
+// attributes parse to the same attributes again.
+func FuzzParseAttributes(f *testing.F) {
+	f.Add(`ID="a,b",DURATION=15.000, CLASS="twitch-stitched-ad",EMPTY=""`)
+	f.Add(`A,B=1,"C"=2,D=x"y,E=`)
+	f.Add(`=,==,"=",A="`)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		attrs := parseAttributes(s)
+
+		var b strings.Builder
+		for i, attr := range attrs {
+			if attr.Key == "" || strings.ContainsAny(attr.Key, `,="`) {
+				t.Fatalf("invalid key %q", attr.Key)
+			}
+			if !attr.Quoted && strings.Contains(attr.Value, ",") {
+				t.Fatalf("unquoted value %q contains a comma", attr.Value)
+			}
+			if attr.Quoted && strings.Contains(attr.Value, `"`) {
+				t.Fatalf("quoted value %q contains a quote", attr.Value)
+			}
+			// Unquoted values that start with a quote or are padded with
+			// spaces cannot be written back unchanged.
+			if !attr.Quoted && (strings.HasPrefix(attr.Value, `"`) || strings.TrimSpace(attr.Value) != attr.Value) {
+				return
+			}
+			if strings.TrimSpace(attr.Key) != attr.Key {
+				return
+			}
+
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(attr.Key + "=")
+			if attr.Quoted {
+				b.WriteString(`"` + attr.Value + `"`)
+			} else {
+				b.WriteString(attr.Value)
+			}
+		}
+
+		if again := parseAttributes(b.String()); len(attrs) > 0 && !reflect.DeepEqual(attrs, again) {
+			t.Errorf("%q parsed to %+v, but %q parsed to %+v", s, attrs, b.String(), again)
+		}
+	})
+}
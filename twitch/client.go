@@ -0,0 +1,58 @@
+// Package twitch implements a client for Twitch's HLS streaming endpoints.
+package twitch
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Client acquires access tokens, resolves playlists and downloads segments.
+// The zero value is ready to use.
+type Client struct {
+	// HTTPClient is used for all requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// OAuthToken is sent when acquiring an access token, if set.
+	OAuthToken *string
+	// DeviceID is sent when acquiring an access token, if set.
+	DeviceID *string
+	// TokenVariables are additional GQL variables sent when acquiring an access token,
+	// such as "platform", "playerType" and "playerBackend".
+	TokenVariables map[string]any
+
+	// Codecs are the video codecs advertised as supported when resolving
+	// playlists, such as "h264". If nil, DefaultCodecs are advertised.
+	Codecs []string
+
+	// Concurrency is the number of segments Stream downloads in parallel.
+	// Values below two download one segment at a time.
+	Concurrency int
+	// Retry controls how Stream retries failed segment requests.
+	Retry RetryPolicy
+
+	// Logger receives non-fatal errors. If nil, they are discarded.
+	Logger *log.Logger
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// supportedCodecs returns the supported_codecs value of playlist requests.
+func (c *Client) supportedCodecs() string {
+	if c.Codecs == nil {
+		return strings.Join(DefaultCodecs, ",")
+	}
+	return strings.Join(c.Codecs, ",")
+}
+
+func (c *Client) logf(format string, v ...any) {
+	if c.Logger == nil {
+		return
+	}
+	c.Logger.Printf(format, v...)
+}
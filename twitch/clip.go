@@ -0,0 +1,116 @@
+package twitch
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// ErrClipNotFound is returned by GetClip when a clip does not exist.
+var ErrClipNotFound = errors.New("clip not found")
+
+//go:embed clip.gql
+var clipQuery string
+
+// Clip is a clip and the qualities it is available in.
+type Clip struct {
+	Slug             string
+	Title            string
+	Game             string
+	BroadcasterID    string
+	BroadcasterLogin string
+	Token            AccessToken
+	Qualities        []ClipQuality
+}
+
+// ClipQuality is a single rendition of a clip.
+type ClipQuality struct {
+	Quality   string  `json:"quality"`
+	FrameRate float64 `json:"frameRate"`
+	SourceURL string  `json:"sourceURL"`
+}
+
+type gqlClip struct {
+	Clip *struct {
+		Title       string `json:"title"`
+		Broadcaster *struct {
+			ID    string `json:"id"`
+			Login string `json:"login"`
+		} `json:"broadcaster"`
+		Game *struct {
+			Name string `json:"name"`
+		} `json:"game"`
+		PlaybackAccessToken AccessToken   `json:"playbackAccessToken"`
+		VideoQualities      []ClipQuality `json:"videoQualities"`
+	} `json:"clip"`
+}
+
+// GetClip fetches the playback access token and qualities of the clip with the given slug.
+func (c *Client) GetClip(ctx context.Context, slug string) (*Clip, error) {
+	var res gqlClip
+	if err := c.gql(ctx, clipQuery, c.tokenVariables("slug", slug), &res, "clip"); err != nil {
+		return nil, err
+	}
+
+	if res.Clip == nil || len(res.Clip.VideoQualities) == 0 {
+		return nil, ErrClipNotFound
+	}
+
+	clip := &Clip{
+		Slug:      slug,
+		Title:     res.Clip.Title,
+		Token:     res.Clip.PlaybackAccessToken,
+		Qualities: res.Clip.VideoQualities,
+	}
+	if res.Clip.Broadcaster != nil {
+		clip.BroadcasterID = res.Clip.Broadcaster.ID
+		clip.BroadcasterLogin = res.Clip.Broadcaster.Login
+	}
+	if res.Clip.Game != nil {
+		clip.Game = res.Clip.Game.Name
+	}
+
+	return clip, nil
+}
+
+// Playlists returns the qualities of the clip as playlists, highest quality
+// first, so they can be listed and selected like those of a stream.
+// Each URL is a single MP4 file signed with the clip's access token.
+func (clip *Clip) Playlists() []PlaylistInfo {
+	query := url.Values{}
+	query.Set("sig", clip.Token.Signature)
+	query.Set("token", clip.Token.Value)
+
+	var playlists []PlaylistInfo
+	for _, q := range clip.Qualities {
+		u, err := url.Parse(q.SourceURL)
+		if err != nil {
+			continue
+		}
+		u.RawQuery = query.Encode()
+
+		group := q.Quality + "p"
+		if q.FrameRate > 0 {
+			group += strconv.Itoa(int(math.Round(q.FrameRate)))
+		}
+
+		height, _ := strconv.Atoi(q.Quality)
+		playlists = append(playlists, PlaylistInfo{
+			Name:      group,
+			Group:     group,
+			Height:    height,
+			FrameRate: q.FrameRate,
+			URL:       u.String(),
+		})
+	}
+
+	sort.SliceStable(playlists, func(i, j int) bool {
+		return playlists[i].Height > playlists[j].Height
+	})
+
+	return playlists
+}
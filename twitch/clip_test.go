@@ -0,0 +1,57 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestGetClip(t *testing.T) {
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		var gqlq gqlQuery
+		ok(t, json.NewDecoder(req.Body).Decode(&gqlq))
+		equals(t, "FunnySlug", gqlq.Variables["slug"])
+
+		return &http.Response{
+			StatusCode: 200,
+			Body: io.NopCloser(bytes.NewBufferString(`{"data":{"clip":{
+"title":"Funny","broadcaster":{"id":"123","login":"username"},"game":{"name":"Just Chatting"},
+"playbackAccessToken":{"signature":"sig","value":"{\"a\":1}"},
+"videoQualities":[
+{"frameRate":30,"quality":"360","sourceURL":"https://example.invalid/360.mp4"},
+{"frameRate":59.94,"quality":"1080","sourceURL":"https://example.invalid/1080.mp4"}
+]}}}`)),
+			Header: make(http.Header),
+		}
+	})}
+
+	clip, err := client.GetClip(context.Background(), "FunnySlug")
+	ok(t, err)
+	equals(t, AccessToken{Value: `{"a":1}`, Signature: "sig"}, clip.Token)
+	equals(t, "Funny", clip.Title)
+	equals(t, "Just Chatting", clip.Game)
+	equals(t, "123", clip.BroadcasterID)
+	equals(t, "username", clip.BroadcasterLogin)
+
+	playlists := clip.Playlists()
+	equals(t, []PlaylistInfo{
+		{
+			Name:      "1080p60",
+			Group:     "1080p60",
+			Height:    1080,
+			FrameRate: 59.94,
+			URL:       "https://example.invalid/1080.mp4?sig=sig&token=%7B%22a%22%3A1%7D",
+		},
+		{
+			Name:      "360p30",
+			Group:     "360p30",
+			Height:    360,
+			FrameRate: 30,
+			URL:       "https://example.invalid/360.mp4?sig=sig&token=%7B%22a%22%3A1%7D",
+		},
+	}, playlists)
+	equals(t, playlists[0], FindBest(playlists))
+}
@@ -0,0 +1,31 @@
+package twitch
+
+const clientID = "kimne78kx3ncx6brgo4mv6wki5h1ko"
+
+const (
+	gqlURL           = "https://gql.twitch.tv/gql"
+	playlistURL      = "https://usher.ttvnw.net/api/channel/hls/%s.m3u8"
+	videoPlaylistURL = "https://usher.ttvnw.net/vod/%s.m3u8"
+)
+
+const (
+	prefetchTag              = "#EXT-X-TWITCH-PREFETCH"
+	versionTag               = "#EXT-X-VERSION"
+	targetDurationTag        = "#EXT-X-TARGETDURATION"
+	mediaSequenceTag         = "#EXT-X-MEDIA-SEQUENCE"
+	discontinuitySequenceTag = "#EXT-X-DISCONTINUITY-SEQUENCE"
+	playlistTypeTag          = "#EXT-X-PLAYLIST-TYPE"
+	independentSegmentsTag   = "#EXT-X-INDEPENDENT-SEGMENTS"
+	endListTag               = "#EXT-X-ENDLIST"
+	mapTag                   = "#EXT-X-MAP"
+	keyTag                   = "#EXT-X-KEY"
+	infTag                   = "#EXTINF"
+	byteRangeTag             = "#EXT-X-BYTERANGE"
+	programDateTimeTag       = "#EXT-X-PROGRAM-DATE-TIME"
+	dateRangeTag             = "#EXT-X-DATERANGE"
+	discontinuityTag         = "#EXT-X-DISCONTINUITY"
+)
+
+const stitchedAdClass = "twitch-stitched-ad"
+
+const maxSeenURLs = 50
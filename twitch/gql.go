@@ -0,0 +1,80 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type gqlQuery struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// gql runs query with variables and decodes the returned data into v.
+// what describes the query in error messages.
+func (c *Client) gql(ctx context.Context, query string, variables map[string]any, v any, what string) error {
+	q := &gqlQuery{
+		Query:     query,
+		Variables: variables,
+	}
+
+	qs, err := json.Marshal(q)
+	if err != nil {
+		return fmt.Errorf("error marshalling GQL query string: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", gqlURL, bytes.NewReader(qs))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Client-ID", clientID)
+
+	if c.OAuthToken != nil {
+		req.Header.Set("Authorization", "OAuth "+*c.OAuthToken)
+	}
+
+	if c.DeviceID != nil {
+		req.Header.Set("Device-ID", *c.DeviceID)
+	}
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("got non-200 http status %s while fetching %s", res.Status, what)
+	}
+
+	var gqlRes gqlResponse
+	if err = json.NewDecoder(res.Body).Decode(&gqlRes); err != nil {
+		return fmt.Errorf("error decoding GQL response: %w", err)
+	}
+
+	if len(gqlRes.Errors) != 0 {
+		var gqlErr string
+		for i := range gqlRes.Errors {
+			gqlErr += gqlRes.Errors[i].Message
+		}
+
+		return fmt.Errorf("GQL returned error(s) while fetching %s: %s", what, gqlErr)
+	}
+
+	if err = json.Unmarshal(gqlRes.Data, v); err != nil {
+		return fmt.Errorf("error decoding %s: %w", what, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,343 @@
+package twitch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrOffline is returned by GetPlaylists when the channel is not live.
+var ErrOffline = errors.New("stream is offline")
+
+// PlaylistInfo describes a single variant of a master playlist.
+type PlaylistInfo struct {
+	Name      string
+	Group     string
+	Bandwidth int
+	Width     int
+	Height    int
+	URL       string
+	Codec     string
+	// FrameRate is the maximum frame rate of the video, or 0 if unknown.
+	FrameRate float64
+
+	// Video and Audio are the GROUP-IDs of the renditions of the variant.
+	Video string
+	Audio string
+	// Renditions are the EXT-X-MEDIA renditions in the VIDEO and AUDIO
+	// groups of the variant.
+	Renditions []Rendition
+	// Attributes are all attributes of the EXT-X-STREAM-INF tag.
+	Attributes map[string]string
+	// TwitchInfo are the attributes of the EXT-X-TWITCH-INFO tag of the
+	// master playlist, shared by all of its variants.
+	TwitchInfo map[string]string
+}
+
+// Rendition is an alternative rendition described by an EXT-X-MEDIA tag.
+type Rendition struct {
+	Type       string
+	GroupID    string
+	Name       string
+	Language   string
+	Default    bool
+	AutoSelect bool
+	// URI is the media playlist of the rendition, if it has its own.
+	URI string
+	// Attributes are all attributes of the EXT-X-MEDIA tag.
+	Attributes map[string]string
+}
+
+// DefaultCodecs are the video codecs advertised as supported when a Client
+// has no Codecs set, most preferred first.
+var DefaultCodecs = []string{"av1", "h265", "h264"}
+
+// videoCodecs maps the prefixes of RFC 6381 codec strings to the codec
+// names used in Client.Codecs.
+var videoCodecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "h265",
+	"hev1": "h265",
+	"av01": "av1",
+}
+
+// VideoCodec returns the name of the video codec of p as used in
+// Client.Codecs, such as "h264", or "" if it has none or it is unknown.
+func (p PlaylistInfo) VideoCodec() string {
+	for _, codec := range strings.Split(p.Codec, ",") {
+		prefix, _, _ := strings.Cut(strings.TrimSpace(codec), ".")
+		if name, ok := videoCodecs[prefix]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// GetPlaylists resolves the master playlist for the live stream of username
+// and returns every variant it lists.
+func (c *Client) GetPlaylists(ctx context.Context, username string, token *AccessToken) ([]PlaylistInfo, error) {
+	pURL, err := url.Parse(fmt.Sprintf(playlistURL, username))
+	if err != nil {
+		return nil, err
+	}
+
+	query := pURL.Query()
+
+	query.Set("allow_source", "true")
+	query.Set("platform", "web")
+	query.Set("player_backend", "mediaplayer")
+	query.Set("playlist_include_framerate", "true")
+	query.Set("reassignments_supported", "true")
+	query.Set("supported_codecs", c.supportedCodecs())
+	query.Set("allow_audio_only", "true")
+	query.Set("fast_bread", "true")
+	query.Set("sig", token.Signature)
+	query.Set("token", token.Value)
+
+	pURL.RawQuery = query.Encode()
+
+	return c.getMasterPlaylist(ctx, pURL.String(), ErrOffline)
+}
+
+// GetVideoPlaylists resolves the master playlist for the video (VOD) with the
+// given ID and returns every variant it lists.
+func (c *Client) GetVideoPlaylists(ctx context.Context, id string, token *AccessToken) ([]PlaylistInfo, error) {
+	pURL, err := url.Parse(fmt.Sprintf(videoPlaylistURL, id))
+	if err != nil {
+		return nil, err
+	}
+
+	query := pURL.Query()
+
+	query.Set("allow_source", "true")
+	query.Set("player_backend", "mediaplayer")
+	query.Set("playlist_include_framerate", "true")
+	query.Set("supported_codecs", c.supportedCodecs())
+	query.Set("allow_audio_only", "true")
+	query.Set("nauthsig", token.Signature)
+	query.Set("nauth", token.Value)
+
+	pURL.RawQuery = query.Encode()
+
+	return c.getMasterPlaylist(ctx, pURL.String(), ErrVideoNotFound)
+}
+
+// getMasterPlaylist fetches and parses the master playlist at pURL,
+// returning notFound if it does not exist.
+func (c *Client) getMasterPlaylist(ctx context.Context, pURL string, notFound error) ([]PlaylistInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		if res.StatusCode != http.StatusNotFound {
+			return nil, fmt.Errorf("playlist got http status %s", res.Status)
+		}
+		return nil, notFound
+	}
+
+	return parseMasterPlaylist(res.Body)
+}
+
+// parseMasterPlaylist parses the variants of the master playlist r.
+// Variants are linked to the renditions of their VIDEO and AUDIO groups;
+// if a variant names no VIDEO group, the EXT-X-MEDIA tag before it is
+// taken to describe it. Malformed tags are skipped.
+func parseMasterPlaylist(r io.Reader) ([]PlaylistInfo, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+
+	var playlists []PlaylistInfo
+	var renditions []Rendition
+	var twitchInfo map[string]string
+
+	// preceding is the index in renditions of the EXT-X-MEDIA tag
+	// before the current variant, or -1.
+	preceding := -1
+	var info *PlaylistInfo
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "#") {
+			if info != nil {
+				info.URL = line
+				playlists = append(playlists, *info)
+				info = nil
+			}
+			continue
+		}
+
+		tag, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		attrs := parseAttributes(value)
+		switch tag {
+		case "#EXT-X-TWITCH-INFO":
+			twitchInfo = attrs.Map()
+		case "#EXT-X-MEDIA":
+			renditions = append(renditions, parseRendition(attrs))
+			preceding = len(renditions) - 1
+		case "#EXT-X-STREAM-INF":
+			info = parseVariant(attrs)
+			if info.Video == "" && preceding >= 0 {
+				info.Group, info.Name = renditions[preceding].GroupID, renditions[preceding].Name
+			}
+			preceding = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range playlists {
+		p := &playlists[i]
+		p.TwitchInfo = twitchInfo
+
+		for _, r := range renditions {
+			if (r.Type == "VIDEO" && r.GroupID == p.Video) || (r.Type == "AUDIO" && r.GroupID == p.Audio) {
+				p.Renditions = append(p.Renditions, r)
+			}
+		}
+
+		if p.Video == "" {
+			continue
+		}
+		p.Group = p.Video
+		for _, r := range p.Renditions {
+			if r.Type == "VIDEO" && (p.Name == "" || r.Default) {
+				p.Name = r.Name
+			}
+		}
+	}
+
+	return playlists, nil
+}
+
+// parseRendition parses the attributes of an EXT-X-MEDIA tag.
+func parseRendition(attrs attributeList) Rendition {
+	r := Rendition{Attributes: attrs.Map()}
+	r.Type, _ = attrs.Get("TYPE")
+	r.GroupID, _ = attrs.Get("GROUP-ID")
+	r.Name, _ = attrs.Get("NAME")
+	r.Language, _ = attrs.Get("LANGUAGE")
+	r.URI, _ = attrs.Get("URI")
+
+	v, _ := attrs.Get("DEFAULT")
+	r.Default = v == "YES"
+	v, _ = attrs.Get("AUTOSELECT")
+	r.AutoSelect = v == "YES"
+
+	return r
+}
+
+// parseVariant parses the attributes of an EXT-X-STREAM-INF tag.
+func parseVariant(attrs attributeList) *PlaylistInfo {
+	info := &PlaylistInfo{Attributes: attrs.Map()}
+	info.Codec, _ = attrs.Get("CODECS")
+	info.Video, _ = attrs.Get("VIDEO")
+	info.Audio, _ = attrs.Get("AUDIO")
+
+	if v, found := attrs.Get("BANDWIDTH"); found {
+		if bandwidth, err := strconv.Atoi(v); err == nil {
+			info.Bandwidth = bandwidth
+		}
+	}
+
+	if v, found := attrs.Get("FRAME-RATE"); found {
+		if frameRate, err := strconv.ParseFloat(v, 64); err == nil && frameRate >= 0 {
+			info.FrameRate = frameRate
+		}
+	}
+
+	if v, found := attrs.Get("RESOLUTION"); found {
+		w, h, _ := strings.Cut(v, "x")
+		width, widthErr := strconv.Atoi(w)
+		height, heightErr := strconv.Atoi(h)
+		if widthErr == nil && heightErr == nil && width >= 0 && height >= 0 {
+			info.Width, info.Height = width, height
+		}
+	}
+
+	return info
+}
+
+// FindBest returns the source variant if present, otherwise the variant
+// with the highest bandwidth, or the first one if no bandwidths are known.
+func FindBest(playlists []PlaylistInfo) PlaylistInfo {
+	var best PlaylistInfo
+	var highBitrate int
+	for i, p := range playlists {
+		if p.Group == "chunked" {
+			return p
+		}
+
+		if p.Bandwidth > highBitrate || i == 0 {
+			highBitrate = p.Bandwidth
+			best = p
+		}
+	}
+
+	return best
+}
+
+// FindPreferred returns the best variant with a video codec in codecs, which
+// are ordered by preference. Variants with a higher resolution or frame rate
+// are better, then the source variant, then variants with a more preferred
+// codec, then those with a higher bandwidth. It returns the zero PlaylistInfo
+// if no variant has a video codec in codecs.
+func FindPreferred(playlists []PlaylistInfo, codecs []string) PlaylistInfo {
+	rank := func(p PlaylistInfo) int {
+		for i, c := range codecs {
+			if c == p.VideoCodec() {
+				return len(codecs) - i
+			}
+		}
+		return 0
+	}
+
+	var best PlaylistInfo
+	for _, p := range playlists {
+		if rank(p) == 0 {
+			continue
+		}
+		if best.URL == "" || betterThan(p, best, rank) {
+			best = p
+		}
+	}
+
+	return best
+}
+
+// betterThan reports whether a is better than b for FindPreferred.
+func betterThan(a, b PlaylistInfo, rank func(PlaylistInfo) int) bool {
+	switch {
+	case a.Height != b.Height:
+		return a.Height > b.Height
+	case a.FrameRate != b.FrameRate:
+		return a.FrameRate > b.FrameRate
+	case (a.Group == "chunked") != (b.Group == "chunked"):
+		return a.Group == "chunked"
+	case rank(a) != rank(b):
+		return rank(a) > rank(b)
+	}
+	return a.Bandwidth > b.Bandwidth
+}
@@ -0,0 +1,224 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGetPlaylist(t *testing.T) {
+	testUsername := "testing"
+	token := &AccessToken{
+		Value:     "token",
+		Signature: "sig",
+	}
+
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, fmt.Sprintf(playlistURL, testUsername),
+			req.URL.String()[:len(req.URL.String())-len(req.URL.RawQuery)-1])
+		equals(t, "true", req.URL.Query().Get("allow_source"))
+		equals(t, "true", req.URL.Query().Get("fast_bread"))
+		equals(t, "av1,h265,h264", req.URL.Query().Get("supported_codecs"))
+		equals(t, token.Signature, req.URL.Query().Get("sig"))
+		equals(t, token.Value, req.URL.Query().Get("token"))
+
+		return &http.Response{
+			StatusCode: 200,
+			Body: io.NopCloser(bytes.NewBufferString(`#EXTM3U
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="chunked",NAME="1080p60 (source)",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=1234567,RESOLUTION=1920x1080,CODECS="avc1.64002A,mp4a.40.2",VIDEO="chunked",FRAME-RATE=60.000
+https://example.invalid/123.m3u8
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="720p60",NAME="720p60",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=7654321,RESOLUTION=1280x720,CODECS="avc1.4D401F,mp4a.40.2",VIDEO="720p60",FRAME-RATE=60.000
+https://example.invalid/456.m3u8`)),
+			Header: make(http.Header),
+		}
+	})}
+
+	playlists, err := client.GetPlaylists(context.Background(), testUsername, token)
+	ok(t, err)
+
+	equals(t, 2, len(playlists))
+	equals(t, "chunked", playlists[0].Group)
+	equals(t, "1080p60 (source)", playlists[0].Name)
+	equals(t, "https://example.invalid/123.m3u8", playlists[0].URL)
+	equals(t, "720p60", playlists[1].Group)
+	equals(t, "https://example.invalid/456.m3u8", playlists[1].URL)
+}
+
+func TestParseMasterPlaylist(t *testing.T) {
+	playlists, err := parseMasterPlaylist(strings.NewReader(`#EXTM3U
+#EXT-X-TWITCH-INFO:NODE="video-edge-1",MANIFEST-NODE-TYPE="weaver_cluster",SERVER-TIME="1700000000.00",BROADCAST-ID="456",STREAM-TIME="10.0"
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="chunked",NAME="1080p60 (source)",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=6000000,RESOLUTION=1920x1080,CODECS="avc1.64002A,mp4a.40.2",VIDEO="chunked",FRAME-RATE=59.940
+
+https://example.invalid/chunked.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=3000000,RESOLUTION=1280x720,CODECS="avc1.4D401F,mp4a.40.2",VIDEO="720p60",AUDIO="aac",FRAME-RATE=60.000
+https://example.invalid/720p60.m3u8
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="720p60",NAME="720p60",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aac",NAME="English",LANGUAGE="en",AUTOSELECT=NO,DEFAULT=NO,URI="https://example.invalid/aac.m3u8"
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="audio_only",NAME="audio_only",AUTOSELECT=NO,DEFAULT=NO
+#EXT-X-STREAM-INF:BANDWIDTH=160000,CODECS="mp4a.40.2"
+https://example.invalid/audio_only.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=oops,RESOLUTION=widexhigh,FRAME-RATE=-1
+https://example.invalid/broken.m3u8
+`))
+	ok(t, err)
+
+	twitchInfo := map[string]string{
+		"NODE":               "video-edge-1",
+		"MANIFEST-NODE-TYPE": "weaver_cluster",
+		"SERVER-TIME":        "1700000000.00",
+		"BROADCAST-ID":       "456",
+		"STREAM-TIME":        "10.0",
+	}
+
+	equals(t, []PlaylistInfo{
+		{
+			Name:      "1080p60 (source)",
+			Group:     "chunked",
+			Bandwidth: 6000000,
+			Width:     1920,
+			Height:    1080,
+			URL:       "https://example.invalid/chunked.m3u8",
+			Codec:     "avc1.64002A,mp4a.40.2",
+			FrameRate: 59.94,
+			Video:     "chunked",
+			Renditions: []Rendition{{
+				Type:       "VIDEO",
+				GroupID:    "chunked",
+				Name:       "1080p60 (source)",
+				Default:    true,
+				AutoSelect: true,
+				Attributes: map[string]string{"TYPE": "VIDEO", "GROUP-ID": "chunked", "NAME": "1080p60 (source)", "AUTOSELECT": "YES", "DEFAULT": "YES"},
+			}},
+			Attributes: map[string]string{"BANDWIDTH": "6000000", "RESOLUTION": "1920x1080", "CODECS": "avc1.64002A,mp4a.40.2", "VIDEO": "chunked", "FRAME-RATE": "59.940"},
+			TwitchInfo: twitchInfo,
+		},
+		{
+			Name:      "720p60",
+			Group:     "720p60",
+			Bandwidth: 3000000,
+			Width:     1280,
+			Height:    720,
+			URL:       "https://example.invalid/720p60.m3u8",
+			Codec:     "avc1.4D401F,mp4a.40.2",
+			FrameRate: 60,
+			Video:     "720p60",
+			Audio:     "aac",
+			Renditions: []Rendition{
+				{
+					Type:       "VIDEO",
+					GroupID:    "720p60",
+					Name:       "720p60",
+					Default:    true,
+					AutoSelect: true,
+					Attributes: map[string]string{"TYPE": "VIDEO", "GROUP-ID": "720p60", "NAME": "720p60", "AUTOSELECT": "YES", "DEFAULT": "YES"},
+				},
+				{
+					Type:       "AUDIO",
+					GroupID:    "aac",
+					Name:       "English",
+					Language:   "en",
+					URI:        "https://example.invalid/aac.m3u8",
+					Attributes: map[string]string{"TYPE": "AUDIO", "GROUP-ID": "aac", "NAME": "English", "LANGUAGE": "en", "AUTOSELECT": "NO", "DEFAULT": "NO", "URI": "https://example.invalid/aac.m3u8"},
+				},
+			},
+			Attributes: map[string]string{"BANDWIDTH": "3000000", "RESOLUTION": "1280x720", "CODECS": "avc1.4D401F,mp4a.40.2", "VIDEO": "720p60", "AUDIO": "aac", "FRAME-RATE": "60.000"},
+			TwitchInfo: twitchInfo,
+		},
+		{
+			// Linked by position, as it names no VIDEO group.
+			Name:       "audio_only",
+			Group:      "audio_only",
+			Bandwidth:  160000,
+			URL:        "https://example.invalid/audio_only.m3u8",
+			Codec:      "mp4a.40.2",
+			Attributes: map[string]string{"BANDWIDTH": "160000", "CODECS": "mp4a.40.2"},
+			TwitchInfo: twitchInfo,
+		},
+		{
+			URL:        "https://example.invalid/broken.m3u8",
+			Attributes: map[string]string{"BANDWIDTH": "oops", "RESOLUTION": "widexhigh", "FRAME-RATE": "-1"},
+			TwitchInfo: twitchInfo,
+		},
+	}, playlists)
+}
+
+// FuzzParseMasterPlaylist checks that malformed master playlists never make
+// the parser panic or return variants without a URL.
+func FuzzParseMasterPlaylist(f *testing.F) {
+	f.Add("#EXTM3U\n#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID=\"chunked\",NAME=\"1080p60\"\n#EXT-X-STREAM-INF:BANDWIDTH=1,VIDEO=\"chunked\"\nhttps://example.invalid/a.m3u8\n")
+	f.Add("#EXT-X-STREAM-INF:RESOLUTION=x,FRAME-RATE=NaN,CODECS=\"\nurl\n#EXT-X-TWITCH-INFO:A=\"\n")
+	f.Add("#EXT-X-STREAM-INF:\n#EXT-X-STREAM-INF:,=,\"\"=\"\"\n\n")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		playlists, err := parseMasterPlaylist(strings.NewReader(s))
+		if err != nil {
+			return
+		}
+		for _, p := range playlists {
+			if p.URL == "" || strings.HasPrefix(p.URL, "#") {
+				t.Errorf("variant with URL %q", p.URL)
+			}
+			if p.Width < 0 || p.Height < 0 || p.FrameRate < 0 {
+				t.Errorf("variant with negative dimensions %+v", p)
+			}
+		}
+	})
+}
+
+func TestFindBest(t *testing.T) {
+	source := PlaylistInfo{Group: "chunked", Bandwidth: 1000}
+	high := PlaylistInfo{Group: "720p60", Bandwidth: 2000}
+	low := PlaylistInfo{Group: "480p30", Bandwidth: 500}
+
+	equals(t, source, FindBest([]PlaylistInfo{low, high, source}))
+	equals(t, high, FindBest([]PlaylistInfo{low, high}))
+	equals(t, PlaylistInfo{}, FindBest(nil))
+}
+
+func TestFindPreferred(t *testing.T) {
+	source := PlaylistInfo{Group: "chunked", Height: 1440, FrameRate: 60, Bandwidth: 8000, Codec: "hvc1.2.4.L123.B0,mp4a.40.2", URL: "source"}
+	av1 := PlaylistInfo{Group: "1080p60", Height: 1080, FrameRate: 60, Bandwidth: 4000, Codec: "av01.0.08M.08,mp4a.40.2", URL: "av1"}
+	h264 := PlaylistInfo{Group: "1080p60_alt", Height: 1080, FrameRate: 60, Bandwidth: 6000, Codec: "avc1.64002A,mp4a.40.2", URL: "h264"}
+	low := PlaylistInfo{Group: "720p30", Height: 720, FrameRate: 30, Bandwidth: 2000, Codec: "avc1.4D401F,mp4a.40.2", URL: "low"}
+	audio := PlaylistInfo{Group: "audio_only", Bandwidth: 160, Codec: "mp4a.40.2", URL: "audio"}
+	playlists := []PlaylistInfo{audio, low, h264, av1, source}
+
+	equals(t, "h265", source.VideoCodec())
+	equals(t, "", audio.VideoCodec())
+
+	equals(t, source, FindPreferred(playlists, DefaultCodecs))
+	equals(t, av1, FindPreferred(playlists, []string{"av1", "h264"}))
+	equals(t, h264, FindPreferred(playlists, []string{"h264", "av1"}))
+	equals(t, PlaylistInfo{}, FindPreferred([]PlaylistInfo{audio}, DefaultCodecs))
+}
+
+func TestGetVideoPlaylists(t *testing.T) {
+	token := &AccessToken{
+		Value:     "token",
+		Signature: "sig",
+	}
+
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, fmt.Sprintf(videoPlaylistURL, "123456"),
+			req.URL.String()[:len(req.URL.String())-len(req.URL.RawQuery)-1])
+		equals(t, token.Signature, req.URL.Query().Get("nauthsig"))
+		equals(t, token.Value, req.URL.Query().Get("nauth"))
+
+		return &http.Response{
+			StatusCode: 404,
+			Status:     "404 Not Found",
+			Body:       io.NopCloser(bytes.NewBufferString("")),
+			Header:     make(http.Header),
+		}
+	})}
+
+	_, err := client.GetVideoPlaylists(context.Background(), "123456", token)
+	equals(t, ErrVideoNotFound, err)
+}
@@ -0,0 +1,229 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Poller reloads a media playlist and emits every new segment exactly once.
+type Poller struct {
+	Client *Client
+	// URL is the media playlist URL.
+	URL string
+	// Archive starts from the oldest segment in the playlist rather than the newest.
+	Archive bool
+	// NextSeq, if non-zero, is the media sequence number of the first segment
+	// to emit, overriding Archive. It is used to resume an earlier download;
+	// segments before it that are no longer in the playlist are reported as a gap.
+	NextSeq int
+	// Refresh, if set, is called when access to the playlist is denied.
+	// It should acquire a new access token and return the URL of the same
+	// media playlist signed with it. Polling continues from the current
+	// media sequence.
+	Refresh func(ctx context.Context) (string, error)
+	// SkipAds withholds segments that belong to stitched advertisements.
+	SkipAds bool
+	// OnGap, if set, is called whenever segments rolled off the playlist
+	// before the poller could see them.
+	OnGap func(Gap)
+	// Interval, if non-zero, overrides the reload interval otherwise derived
+	// from the playlist's target and segment durations.
+	Interval time.Duration
+}
+
+// Gap is a run of consecutive segments that were missed between two reloads
+// of a media playlist.
+type Gap struct {
+	FirstSeq int `json:"first_seq"`
+	LastSeq  int `json:"last_seq"`
+	// Duration is the estimated duration of the missed segments in seconds.
+	Duration   float64   `json:"duration"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// Count returns the number of missed segments.
+func (g Gap) Count() int {
+	return g.LastSeq - g.FirstSeq + 1
+}
+
+// findGap reports the segments between nextSeq and the start of mp, if any.
+func findGap(mp *MediaPlaylist, nextSeq int) (Gap, bool) {
+	if mp == nil || len(mp.Segments) == 0 || mp.Segments[0].Seq <= nextSeq {
+		return Gap{}, false
+	}
+
+	gap := Gap{
+		FirstSeq:   nextSeq,
+		LastSeq:    mp.Segments[0].Seq - 1,
+		DetectedAt: time.Now(),
+	}
+
+	perSegment := mp.TargetDuration
+	if perSegment == 0 {
+		var total float64
+		for _, s := range mp.Segments {
+			total += s.Duration
+		}
+		perSegment = total / float64(len(mp.Segments))
+	}
+	gap.Duration = perSegment * float64(gap.Count())
+
+	return gap, true
+}
+
+// edgeTime returns when the segment at index i of mp, loaded at loaded, was
+// the newest in the playlist, or the zero time if mp has ended.
+func edgeTime(mp *MediaPlaylist, i int, loaded time.Time) time.Time {
+	if mp.EndList {
+		return time.Time{}
+	}
+
+	var behind float64
+	for _, s := range mp.Segments[i+1:] {
+		behind += s.Duration
+	}
+	return loaded.Add(-time.Duration(behind * float64(time.Second)))
+}
+
+// defaultInterval is the reload interval used when the playlist carries no
+// duration information.
+const defaultInterval = time.Second * 1
+
+// reloadDelay returns how long to wait before reloading mp, following the
+// reload rules of RFC 8216 section 6.3.4: the duration of the last segment if
+// the playlist changed, and half the target duration if it did not.
+func reloadDelay(mp *MediaPlaylist, changed bool) time.Duration {
+	if mp == nil {
+		return defaultInterval
+	}
+
+	var seconds float64
+	if changed {
+		for i := len(mp.Segments) - 1; i >= 0; i-- {
+			if !mp.Segments[i].Prefetch && mp.Segments[i].Duration > 0 {
+				seconds = mp.Segments[i].Duration
+				break
+			}
+		}
+		if seconds == 0 {
+			seconds = mp.TargetDuration
+		}
+	} else {
+		seconds = mp.TargetDuration / 2
+	}
+
+	if seconds <= 0 {
+		return defaultInterval
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Run polls the media playlist until the stream ends or ctx is cancelled,
+// sending each new segment to segments in order. segments is closed when Run returns.
+// Run returns nil once the stream is over.
+func (p *Poller) Run(ctx context.Context, segments chan<- Segment) error {
+	defer close(segments)
+
+	var currentSeq int
+	var loaded bool
+	var inAd bool
+	mp, urlsErr := p.Client.GetMediaPlaylist(ctx, p.URL)
+	loadedAt := time.Now()
+	if p.NextSeq > 0 {
+		currentSeq = p.NextSeq
+		loaded = true
+	} else if !p.Archive && mp != nil && len(mp.Segments) > 1 {
+		currentSeq = mp.Segments[len(mp.Segments)-1].Seq
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if urlsErr == ErrUnauthorized && p.Refresh != nil {
+			p.Client.logf("%v, refreshing access token\n", urlsErr)
+			if url, err := p.Refresh(ctx); err == nil {
+				p.URL = url
+			} else {
+				p.Client.logf("could not refresh access token: %v\n", err)
+			}
+		} else if urlsErr != nil && urlsErr != ErrStreamOver {
+			p.Client.logf("could not get prefetch URLs: %v\n", urlsErr)
+		}
+
+		if gap, found := findGap(mp, currentSeq); found && loaded {
+			p.Client.logf("missed %d segment(s) %d-%d (~%.1fs), playlist reloads are falling behind\n", gap.Count(), gap.FirstSeq, gap.LastSeq, gap.Duration)
+			if p.OnGap != nil {
+				p.OnGap(gap)
+			}
+		}
+
+		var changed bool
+		if mp != nil {
+			loaded = true
+			for i, url := range mp.Segments {
+				if url.Seq < currentSeq {
+					continue
+				}
+				currentSeq = url.Seq + 1
+				changed = true
+				url.EdgeTime = edgeTime(mp, i, loadedAt)
+
+				if url.Ad != inAd {
+					inAd = url.Ad
+					p.logAd(inAd, url)
+				}
+
+				if url.Ad && p.SkipAds {
+					continue
+				}
+
+				select {
+				case segments <- url:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+
+		if urlsErr == ErrStreamOver || (mp != nil && mp.EndList) {
+			return nil
+		}
+
+		delay := p.Interval
+		if delay == 0 {
+			delay = reloadDelay(mp, changed)
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		mp, urlsErr = p.Client.GetMediaPlaylist(ctx, p.URL)
+		loadedAt = time.Now()
+	}
+}
+
+func (p *Poller) logAd(start bool, s Segment) {
+	if !start {
+		p.Client.logf("ad break ended\n")
+		return
+	}
+
+	msg := "ad break started"
+	for _, dr := range s.DateRanges {
+		if dr.Class == stitchedAdClass && dr.Duration > 0 {
+			msg += fmt.Sprintf(" (%v)", dr.Duration)
+			break
+		}
+	}
+	if p.SkipAds {
+		msg += ", skipping"
+	}
+	p.Client.logf("%s\n", msg)
+}
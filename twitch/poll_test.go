@@ -0,0 +1,181 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPollerRun(t *testing.T) {
+	playlists := []string{
+		"#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:10\n#EXTINF:2.000,live\nhttps://example.invalid/10.ts\n#EXTINF:2.000,live\nhttps://example.invalid/11.ts\n",
+		"#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:11\n#EXTINF:2.000,live\nhttps://example.invalid/11.ts\n#EXTINF:2.000,live\nhttps://example.invalid/12.ts\n#EXT-X-ENDLIST\n",
+	}
+
+	var reloads int
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, "https://example.invalid/123.m3u8", req.URL.String())
+		body := playlists[reloads]
+		reloads++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+			Header:     make(http.Header),
+		}
+	})}
+
+	for _, archive := range []bool{true, false} {
+		reloads = 0
+		poller := &Poller{
+			Client:   client,
+			URL:      "https://example.invalid/123.m3u8",
+			Archive:  archive,
+			Interval: time.Millisecond,
+		}
+
+		segments := make(chan Segment, 10)
+		ok(t, poller.Run(context.Background(), segments))
+
+		var got []int
+		for s := range segments {
+			got = append(got, s.Seq)
+		}
+
+		exp := []int{11, 12}
+		if archive {
+			exp = []int{10, 11, 12}
+		}
+		equals(t, fmt.Sprint(exp), fmt.Sprint(got))
+	}
+}
+
+func TestPollerResume(t *testing.T) {
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString("#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:10\n#EXT-X-TARGETDURATION:2\nhttps://example.invalid/10.ts\nhttps://example.invalid/11.ts\n#EXT-X-ENDLIST\n")),
+			Header:     make(http.Header),
+		}
+	})}
+
+	tests := []struct {
+		nextSeq int
+		exp     []int
+		gaps    []Gap
+	}{
+		{11, []int{11}, nil},
+		{8, []int{10, 11}, []Gap{{FirstSeq: 8, LastSeq: 9, Duration: 4}}},
+	}
+
+	for _, test := range tests {
+		var gaps []Gap
+		poller := &Poller{
+			Client:   client,
+			URL:      "https://example.invalid/123.m3u8",
+			NextSeq:  test.nextSeq,
+			Interval: time.Millisecond,
+			OnGap: func(g Gap) {
+				g.DetectedAt = time.Time{}
+				gaps = append(gaps, g)
+			},
+		}
+
+		segments := make(chan Segment, 10)
+		ok(t, poller.Run(context.Background(), segments))
+
+		var got []int
+		for s := range segments {
+			got = append(got, s.Seq)
+		}
+		equals(t, fmt.Sprint(test.exp), fmt.Sprint(got))
+		equals(t, test.gaps, gaps)
+	}
+}
+
+func TestPollerRefresh(t *testing.T) {
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		if req.URL.Query().Get("token") != "new" {
+			return &http.Response{
+				StatusCode: 403,
+				Status:     "403 Forbidden",
+				Body:       io.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString("#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:10\nhttps://example.invalid/10.ts\n#EXT-X-ENDLIST\n")),
+			Header:     make(http.Header),
+		}
+	})}
+
+	var refreshes int
+	poller := &Poller{
+		Client:   client,
+		URL:      "https://example.invalid/123.m3u8?token=old",
+		Archive:  true,
+		Interval: time.Millisecond,
+		Refresh: func(ctx context.Context) (string, error) {
+			refreshes++
+			return "https://example.invalid/123.m3u8?token=new", nil
+		},
+	}
+
+	segments := make(chan Segment, 10)
+	ok(t, poller.Run(context.Background(), segments))
+	equals(t, 1, refreshes)
+	equals(t, 10, (<-segments).Seq)
+}
+
+func TestReloadDelay(t *testing.T) {
+	mp := &MediaPlaylist{
+		TargetDuration: 6,
+		Segments: []Segment{
+			{Duration: 6},
+			{Duration: 4},
+			{Prefetch: true},
+		},
+	}
+
+	equals(t, time.Second*4, reloadDelay(mp, true))
+	equals(t, time.Second*3, reloadDelay(mp, false))
+	equals(t, defaultInterval, reloadDelay(&MediaPlaylist{}, true))
+	equals(t, defaultInterval, reloadDelay(nil, false))
+}
+
+func TestFindGap(t *testing.T) {
+	mp := &MediaPlaylist{
+		TargetDuration: 2,
+		Segments:       []Segment{{Seq: 15, Duration: 2}, {Seq: 16, Duration: 2}},
+	}
+
+	gap, found := findGap(mp, 12)
+	equals(t, true, found)
+	equals(t, 12, gap.FirstSeq)
+	equals(t, 14, gap.LastSeq)
+	equals(t, 3, gap.Count())
+	equals(t, 6.0, gap.Duration)
+
+	_, found = findGap(mp, 15)
+	equals(t, false, found)
+	_, found = findGap(nil, 15)
+	equals(t, false, found)
+}
+
+func TestEdgeTime(t *testing.T) {
+	loaded := time.Date(2022, 1, 1, 0, 0, 10, 0, time.UTC)
+	mp := &MediaPlaylist{
+		Segments: []Segment{{Duration: 2}, {Duration: 2}, {Duration: 2.5}, {Prefetch: true}},
+	}
+
+	equals(t, loaded.Add(-time.Millisecond*4500), edgeTime(mp, 0, loaded))
+	equals(t, loaded.Add(-time.Millisecond*2500), edgeTime(mp, 1, loaded))
+	equals(t, loaded, edgeTime(mp, 3, loaded))
+
+	mp.EndList = true
+	equals(t, time.Time{}, edgeTime(mp, 0, loaded))
+}
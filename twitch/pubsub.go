@@ -0,0 +1,217 @@
+package twitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	pubSubURL    = "wss://pubsub-edge.twitch.tv/v1"
+	pubSubOrigin = "https://www.twitch.tv"
+
+	playbackTopic = "video-playback-by-id."
+
+	// pubSubPingInterval must be below five minutes, after which PubSub
+	// drops connections that have not sent a PING.
+	pubSubPingInterval = time.Minute * 4
+	pubSubPongTimeout  = time.Second * 10
+	// pubSubMaxTopics is the maximum number of topics per LISTEN message.
+	pubSubMaxTopics = 50
+)
+
+// ErrReconnect is returned by WatchPlayback when PubSub asks clients to reconnect.
+var ErrReconnect = errors.New("pubsub requested reconnect")
+
+// PlaybackEvent is a video-playback-by-id PubSub event.
+type PlaybackEvent struct {
+	ChannelID string
+	// Type is the event type, such as "stream-up", "stream-down" or "viewcount".
+	Type string
+}
+
+type pubSubMessage struct {
+	Type  string      `json:"type"`
+	Nonce string      `json:"nonce,omitempty"`
+	Error string      `json:"error,omitempty"`
+	Data  *pubSubData `json:"data,omitempty"`
+}
+
+type pubSubData struct {
+	Topics []string `json:"topics,omitempty"`
+	Topic  string   `json:"topic,omitempty"`
+	// Message is itself JSON encoded.
+	Message string `json:"message,omitempty"`
+}
+
+// pubSubConn is an open PubSub connection.
+type pubSubConn struct {
+	// messages receives the messages read from the connection, and
+	// errs the error that ended reading.
+	messages <-chan pubSubMessage
+	errs     <-chan error
+	send     func(msg *pubSubMessage) error
+}
+
+// WatchPlayback listens to the video-playback-by-id PubSub topics of the given
+// channel IDs and sends every event received to events. It returns once ctx is
+// cancelled or the connection fails, after which the caller should reconnect.
+func (c *Client) WatchPlayback(ctx context.Context, channelIDs []string, events chan<- PlaybackEvent) error {
+	config, err := websocket.NewConfig(pubSubURL, pubSubOrigin)
+	if err != nil {
+		return err
+	}
+	config.Dialer = &net.Dialer{Timeout: time.Second * 10}
+
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		ws.Close()
+	}()
+
+	for _, listen := range listenMessages(channelIDs) {
+		if err := websocket.JSON.Send(ws, &listen); err != nil {
+			return err
+		}
+	}
+
+	messages := make(chan pubSubMessage)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			var msg pubSubMessage
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				readErr <- err
+				return
+			}
+
+			select {
+			case messages <- msg:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(pubSubPingInterval)
+	defer ping.Stop()
+
+	conn := pubSubConn{
+		messages: messages,
+		errs:     readErr,
+		send: func(msg *pubSubMessage) error {
+			return websocket.JSON.Send(ws, msg)
+		},
+	}
+	return c.servePubSub(ctx, conn, ping.C, pubSubPongTimeout, events)
+}
+
+// listenMessages returns the LISTEN messages subscribing to the playback
+// topics of channelIDs, at most pubSubMaxTopics per message.
+func listenMessages(channelIDs []string) []pubSubMessage {
+	var messages []pubSubMessage
+	for i := 0; i < len(channelIDs); i += pubSubMaxTopics {
+		end := i + pubSubMaxTopics
+		if end > len(channelIDs) {
+			end = len(channelIDs)
+		}
+
+		listen := pubSubMessage{Type: "LISTEN", Nonce: strconv.Itoa(i), Data: &pubSubData{}}
+		for _, id := range channelIDs[i:end] {
+			listen.Data.Topics = append(listen.Data.Topics, playbackTopic+id)
+		}
+		messages = append(messages, listen)
+	}
+	return messages
+}
+
+// servePubSub handles the messages of conn until it fails or ctx is cancelled,
+// sending a PING whenever ping fires and failing if no PONG arrives within
+// pongTimeout.
+func (c *Client) servePubSub(ctx context.Context, conn pubSubConn, ping <-chan time.Time, pongTimeout time.Duration, events chan<- PlaybackEvent) error {
+	var pongDeadline <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-conn.errs:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		case <-ping:
+			if err := conn.send(&pubSubMessage{Type: "PING"}); err != nil {
+				return err
+			}
+			pongDeadline = time.After(pongTimeout)
+		case <-pongDeadline:
+			return errors.New("pubsub did not respond to ping")
+		case msg := <-conn.messages:
+			if msg.Type == "PONG" {
+				pongDeadline = nil
+				continue
+			}
+
+			event, err := c.handlePubSubMessage(msg)
+			if err != nil {
+				return err
+			}
+			if event == nil {
+				continue
+			}
+
+			select {
+			case events <- *event:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// handlePubSubMessage returns the playback event carried by msg, if any,
+// or an error if the connection should be closed.
+func (c *Client) handlePubSubMessage(msg pubSubMessage) (*PlaybackEvent, error) {
+	switch msg.Type {
+	case "RECONNECT":
+		return nil, ErrReconnect
+	case "RESPONSE":
+		if msg.Error != "" {
+			return nil, fmt.Errorf("pubsub returned error: %s", msg.Error)
+		}
+	case "MESSAGE":
+		if msg.Data == nil || !strings.HasPrefix(msg.Data.Topic, playbackTopic) {
+			break
+		}
+
+		var event struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(msg.Data.Message), &event); err != nil {
+			c.logf("could not decode pubsub message: %v\n", err)
+			break
+		}
+
+		return &PlaybackEvent{
+			ChannelID: strings.TrimPrefix(msg.Data.Topic, playbackTopic),
+			Type:      event.Type,
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,135 @@
+package twitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestListenMessages(t *testing.T) {
+	var ids []string
+	for i := 0; i < 120; i++ {
+		ids = append(ids, fmt.Sprint(i))
+	}
+
+	messages := listenMessages(ids)
+	equals(t, 3, len(messages))
+	for i, n := range []int{50, 50, 20} {
+		equals(t, "LISTEN", messages[i].Type)
+		equals(t, fmt.Sprint(i*50), messages[i].Nonce)
+		equals(t, n, len(messages[i].Data.Topics))
+		equals(t, playbackTopic+fmt.Sprint(i*50), messages[i].Data.Topics[0])
+	}
+
+	equals(t, 0, len(listenMessages(nil)))
+}
+
+func TestHandlePubSubMessage(t *testing.T) {
+	message := func(topic, msg string) pubSubMessage {
+		return pubSubMessage{Type: "MESSAGE", Data: &pubSubData{Topic: topic, Message: msg}}
+	}
+
+	tests := []struct {
+		msg   pubSubMessage
+		event *PlaybackEvent
+		err   string
+	}{
+		{pubSubMessage{Type: "RESPONSE", Nonce: "0"}, nil, ""},
+		{pubSubMessage{Type: "RESPONSE", Nonce: "0", Error: "ERR_BADAUTH"}, nil, "pubsub returned error: ERR_BADAUTH"},
+		{pubSubMessage{Type: "RECONNECT"}, nil, ErrReconnect.Error()},
+		{message(playbackTopic+"123", `{"type":"stream-up","server_time":1640995200}`), &PlaybackEvent{ChannelID: "123", Type: "stream-up"}, ""},
+		{message(playbackTopic+"123", `{"type":"viewcount","viewers":10}`), &PlaybackEvent{ChannelID: "123", Type: "viewcount"}, ""},
+		{message(playbackTopic+"123", `not json`), nil, ""},
+		{message("other-topic.123", `{"type":"stream-up"}`), nil, ""},
+		{pubSubMessage{Type: "MESSAGE"}, nil, ""},
+	}
+
+	client := &Client{}
+	for _, test := range tests {
+		event, err := client.handlePubSubMessage(test.msg)
+		if test.err == "" {
+			ok(t, err)
+		} else {
+			assert(t, err != nil && err.Error() == test.err, "%+v: expected error %q, got %v", test.msg, test.err, err)
+		}
+		equals(t, test.event, event)
+	}
+}
+
+func TestServePubSub(t *testing.T) {
+	client := &Client{}
+
+	// serve feeds msgs to servePubSub, then ends the connection with end.
+	serve := func(ping <-chan time.Time, send func(*pubSubMessage) error, end error, msgs ...pubSubMessage) ([]PlaybackEvent, error) {
+		messages := make(chan pubSubMessage)
+		errs := make(chan error)
+		go func() {
+			for _, msg := range msgs {
+				messages <- msg
+			}
+			if end != nil {
+				errs <- end
+			}
+		}()
+
+		events := make(chan PlaybackEvent, len(msgs))
+		conn := pubSubConn{messages: messages, errs: errs, send: send}
+		err := client.servePubSub(context.Background(), conn, ping, time.Millisecond*10, events)
+		close(events)
+
+		var got []PlaybackEvent
+		for e := range events {
+			got = append(got, e)
+		}
+		return got, err
+	}
+
+	noSend := func(msg *pubSubMessage) error {
+		t.Errorf("unexpected %s message sent", msg.Type)
+		return nil
+	}
+
+	up := pubSubMessage{Type: "MESSAGE", Data: &pubSubData{Topic: playbackTopic + "1", Message: `{"type":"stream-up"}`}}
+	events, err := serve(nil, noSend, io.EOF, pubSubMessage{Type: "RESPONSE"}, up, up)
+	equals(t, io.EOF, err)
+	equals(t, []PlaybackEvent{{"1", "stream-up"}, {"1", "stream-up"}}, events)
+
+	events, err = serve(nil, noSend, nil, up, pubSubMessage{Type: "RECONNECT"}, up)
+	equals(t, ErrReconnect, err)
+	equals(t, []PlaybackEvent{{"1", "stream-up"}}, events)
+
+	// A PING that is not answered ends the connection.
+	ping := make(chan time.Time, 1)
+	ping <- time.Now()
+	var sent []string
+	send := func(msg *pubSubMessage) error {
+		sent = append(sent, msg.Type)
+		return nil
+	}
+	_, err = serve(ping, send, nil)
+	equals(t, "pubsub did not respond to ping", fmt.Sprint(err))
+	equals(t, []string{"PING"}, sent)
+
+	// A PONG within the timeout keeps it open.
+	ping <- time.Now()
+	messages := make(chan pubSubMessage)
+	errs := make(chan error)
+	conn := pubSubConn{messages: messages, errs: errs, send: func(msg *pubSubMessage) error {
+		go func() {
+			messages <- pubSubMessage{Type: "PONG"}
+			time.Sleep(time.Millisecond * 50)
+			errs <- io.EOF
+		}()
+		return nil
+	}}
+	err = client.servePubSub(context.Background(), conn, ping, time.Millisecond*10, nil)
+	equals(t, io.EOF, err)
+
+	failed := errors.New("failed")
+	ping <- time.Now()
+	_, err = serve(ping, func(*pubSubMessage) error { return failed }, nil)
+	equals(t, failed, err)
+}
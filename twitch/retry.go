@@ -0,0 +1,55 @@
+package twitch
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how Stream retries segment requests that failed
+// with a temporary error. The zero value retries immediately and indefinitely.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts per request, or 0 for no limit.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles after every
+	// further attempt, with up to half of it randomised.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between attempts, or 0 for no cap.
+	MaxBackoff time.Duration
+	// Deadline is how far a segment may fall behind the live edge before
+	// its requests are no longer retried, or 0 for no limit. It is measured
+	// from Segment.EdgeTime, so it does not apply to playlists that have
+	// ended, such as those of VODs.
+	Deadline time.Duration
+}
+
+// delay returns the time to wait before the given retry, counting from 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	if p.Backoff <= 0 {
+		return 0
+	}
+
+	d := p.Backoff
+	// Without a cap, doubling stops before it would overflow.
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff) && d <= math.MaxInt64/2; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// exhausted reports whether no further attempt may be made after attempts
+// attempts at a segment that was at the live edge at edge, if the next one
+// would happen after waiting delay.
+func (p RetryPolicy) exhausted(attempts int, edge time.Time, delay time.Duration) bool {
+	if p.MaxAttempts > 0 && attempts >= p.MaxAttempts {
+		return true
+	}
+	if p.Deadline > 0 && !edge.IsZero() && time.Since(edge)+delay > p.Deadline {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: time.Second * 3}
+
+	for retry, max := range []time.Duration{time.Second, time.Second * 2, time.Second * 3, time.Second * 3} {
+		d := p.delay(retry + 1)
+		assert(t, d >= max/2 && d <= max, "retry %d: delay %v not within [%v, %v]", retry+1, d, max/2, max)
+	}
+
+	equals(t, time.Duration(0), RetryPolicy{}.delay(3))
+
+	// Without a cap, the delay keeps growing without overflowing.
+	p = RetryPolicy{Backoff: time.Nanosecond}
+	for _, retry := range []int{63, 64, 100, 1000} {
+		d := p.delay(retry)
+		assert(t, d >= math.MaxInt64/4, "retry %d: delay %v overflowed", retry, d)
+	}
+}
+
+func TestFetchGivesUp(t *testing.T) {
+	var attempts int
+	client := &Client{
+		Retry: RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+		HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+			attempts++
+			return &http.Response{
+				StatusCode: 503,
+				Status:     "503 Service Unavailable",
+				Body:       io.NopCloser(bytes.NewBufferString("")),
+				Header:     make(http.Header),
+			}
+		}),
+	}
+
+	var out bytes.Buffer
+	err := client.fetch(context.Background(), context.Background(), resource{uri: "https://example.invalid/123.ts"}, &out)
+	_, skipped := err.(*skipError)
+	assert(t, skipped, "expected skipError, got %v", err)
+	equals(t, 3, attempts)
+}
+
+func TestRetryPolicyExhausted(t *testing.T) {
+	p := RetryPolicy{Deadline: time.Second * 10}
+
+	equals(t, false, p.exhausted(1, time.Now().Add(-time.Second*5), time.Second))
+	equals(t, true, p.exhausted(1, time.Now().Add(-time.Second*9), time.Second*2))
+	// Ended playlists have no live edge to fall behind.
+	equals(t, false, p.exhausted(100, time.Time{}, time.Hour))
+
+	p.MaxAttempts = 3
+	equals(t, true, p.exhausted(3, time.Time{}, 0))
+}
@@ -0,0 +1,406 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// uncancelled keeps the values of its parent context but is never cancelled,
+// so a segment that is already being written can finish after ctx is cancelled.
+type uncancelled struct {
+	context.Context
+}
+
+func (uncancelled) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (uncancelled) Done() <-chan struct{}       { return nil }
+func (uncancelled) Err() error                  { return nil }
+
+// SegmentWriter is implemented by outputs that need to know where segments
+// begin, for example to split the stream into separate files.
+type SegmentWriter interface {
+	io.Writer
+	// BeginSegment is called before segment is written. If it returns true,
+	// the segment is preceded by its initialization section, if it has one,
+	// so that the output can be played from this segment on.
+	BeginSegment(segment Segment) (bool, error)
+	// EndSegment is called once segment has been written completely.
+	// It is not called for segments that were begun but then had to be
+	// skipped, or were interrupted.
+	EndSegment(segment Segment) error
+}
+
+// InitWriter is implemented by outputs that need to tell initialization
+// sections apart from media segments. Initialization sections written to
+// such an output are passed to WriteInit rather than Write.
+type InitWriter interface {
+	WriteInit(p []byte) (int, error)
+}
+
+// initWriter writes initialization sections to an InitWriter.
+type initWriter struct {
+	InitWriter
+}
+
+func (w initWriter) Write(p []byte) (int, error) {
+	return w.WriteInit(p)
+}
+
+// initOutput returns the writer initialization sections are written to out with.
+func initOutput(out io.Writer) io.Writer {
+	if iw, ok := out.(InitWriter); ok {
+		return initWriter{iw}
+	}
+	return out
+}
+
+// Stream downloads every segment received from segments and writes it to out,
+// preceded by its initialization section whenever one is required.
+// Stream returns nil once segments is closed, or the first error writing to out.
+//
+// If c.Concurrency is greater than one, up to that many segments are
+// downloaded in parallel into memory and written to out in order.
+// Otherwise each segment is copied to out as it is downloaded.
+//
+// If out is a SegmentWriter, it is told about every segment before it is written,
+// and if it is an InitWriter, initialization sections are written with WriteInit.
+//
+// Cancelling ctx stops Stream at the next segment boundary: a segment that is
+// already being written is finished, one that is still being downloaded or
+// retried is dropped, and ctx.Err() is returned.
+func (c *Client) Stream(ctx context.Context, segments <-chan Segment, out io.Writer) error {
+	if c.Concurrency > 1 {
+		return c.streamConcurrent(ctx, segments, out)
+	}
+
+	var needInit = true
+	for {
+		var segment Segment
+		select {
+		case s, ok := <-segments:
+			if !ok {
+				return nil
+			}
+			segment = s
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if segment.Discontinuity {
+			needInit = true
+		}
+
+		if err := unsupported(segment); err != nil {
+			c.logf("%v\n", err)
+			continue
+		}
+
+		sw, _ := out.(SegmentWriter)
+		if sw != nil {
+			reinit, err := sw.BeginSegment(segment)
+			if err != nil {
+				return &fatalError{fmt.Errorf("error while writing ts to output: %w", err)}
+			}
+			if reinit {
+				needInit = true
+			}
+		}
+
+		// The initialization section is only considered written once it has
+		// been downloaded, and without it the segment cannot be played.
+		if needInit && segment.MapURI != "" {
+			err := c.fetch(ctx, uncancelled{ctx}, initResource(segment), initOutput(out))
+			if _, ok := err.(*skipError); ok {
+				c.logf("%v\n", err)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			needInit = false
+		}
+
+		err := c.fetch(ctx, uncancelled{ctx}, mediaResource(segment), out)
+		if _, ok := err.(*skipError); ok {
+			c.logf("%v\n", err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if sw != nil {
+			if err := sw.EndSegment(segment); err != nil {
+				return &fatalError{fmt.Errorf("error while writing ts to output: %w", err)}
+			}
+		}
+	}
+}
+
+// streamConcurrent is Stream with a bounded pool of downloads.
+func (c *Client) streamConcurrent(ctx context.Context, segments <-chan Segment, out io.Writer) error {
+	dlCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type download struct {
+		segment Segment
+		// data receives the contents of each resource, the initialization
+		// section first if it was downloaded, or nil if the segment was skipped.
+		data chan [][]byte
+	}
+
+	// The writer waits on one download while the rest queue up behind it,
+	// bounding the number of outstanding downloads to c.Concurrency.
+	pending := make(chan download, c.Concurrency-1)
+	go func() {
+		defer close(pending)
+
+		// The initialization section is downloaded along with the segments
+		// that will most likely need it. The writer fetches it separately
+		// if it turns out to be needed for another segment.
+		var expectInit = true
+		for {
+			var segment Segment
+			select {
+			case s, ok := <-segments:
+				if !ok {
+					return
+				}
+				segment = s
+			case <-dlCtx.Done():
+				return
+			}
+
+			if segment.Discontinuity {
+				expectInit = true
+			}
+
+			d := download{segment, make(chan [][]byte, 1)}
+			if err := unsupported(segment); err != nil {
+				// Still passed on, so the writer sees its discontinuity.
+				c.logf("%v\n", err)
+				d.data <- nil
+			} else {
+				resources := []resource{mediaResource(segment)}
+				if expectInit && segment.MapURI != "" {
+					resources = append([]resource{initResource(segment)}, resources...)
+				}
+				expectInit = false
+
+				go func() {
+					d.data <- c.download(dlCtx, resources)
+				}()
+			}
+
+			select {
+			case pending <- d:
+			case <-dlCtx.Done():
+				return
+			}
+		}
+	}()
+
+	sw, _ := out.(SegmentWriter)
+	var needInit = true
+	for d := range pending {
+		var data [][]byte
+		select {
+		case data = <-d.data:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if d.segment.Discontinuity {
+			needInit = true
+		}
+
+		if data == nil {
+			continue
+		}
+
+		if sw != nil {
+			reinit, err := sw.BeginSegment(d.segment)
+			if err != nil {
+				return &fatalError{fmt.Errorf("error while writing ts to output: %w", err)}
+			}
+			if reinit {
+				needInit = true
+			}
+		}
+
+		media := data[len(data)-1]
+		if needInit && d.segment.MapURI != "" {
+			if len(data) > 1 {
+				if _, err := initOutput(out).Write(data[0]); err != nil {
+					return &fatalError{fmt.Errorf("error while writing ts to output: %w", err)}
+				}
+			} else {
+				// The segment that was downloaded with the initialization
+				// section was skipped, or this one was not expected to need it.
+				err := c.fetch(ctx, uncancelled{ctx}, initResource(d.segment), initOutput(out))
+				if _, ok := err.(*skipError); ok {
+					c.logf("%v\n", err)
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+			needInit = false
+		}
+
+		if _, err := out.Write(media); err != nil {
+			return &fatalError{fmt.Errorf("error while writing ts to output: %w", err)}
+		}
+
+		if sw != nil {
+			if err := sw.EndSegment(d.segment); err != nil {
+				return &fatalError{fmt.Errorf("error while writing ts to output: %w", err)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// download fetches resources into memory, one buffer each.
+// It returns nil if any of them had to be skipped.
+func (c *Client) download(ctx context.Context, resources []resource) [][]byte {
+	var data [][]byte
+	for _, r := range resources {
+		var buf bytes.Buffer
+		if err := c.fetch(ctx, ctx, r, &buf); err != nil {
+			if ctx.Err() == nil {
+				c.logf("%v\n", err)
+			}
+			return nil
+		}
+		data = append(data, buf.Bytes())
+	}
+	return data
+}
+
+// resource is a URI to download, or the part of it given by byteRange if set.
+type resource struct {
+	uri       string
+	byteRange *ByteRange
+	// edge is the EdgeTime of the segment the resource belongs to.
+	edge time.Time
+}
+
+// mediaResource returns the resource holding the media data of segment.
+func mediaResource(segment Segment) resource {
+	return resource{segment.URI, segment.ByteRange, segment.EdgeTime}
+}
+
+// initResource returns the resource holding the initialization section of segment.
+func initResource(segment Segment) resource {
+	return resource{segment.MapURI, segment.MapByteRange, segment.EdgeTime}
+}
+
+// unsupported returns an error if segment cannot be downloaded,
+// which is the case for encrypted segments.
+func unsupported(segment Segment) error {
+	if segment.Key != nil {
+		return &skipError{fmt.Errorf("segment %d is encrypted with %s, which is not supported", segment.Seq, segment.Key.Method)}
+	}
+	return nil
+}
+
+// fetch copies r to out, retrying according to c.Retry.
+// Requests are made with reqCtx. The returned error is a *skipError,
+// a *fatalError or ctx.Err().
+func (c *Client) fetch(ctx, reqCtx context.Context, r resource, out io.Writer) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := func() error {
+			req, err := http.NewRequestWithContext(reqCtx, "GET", r.uri, nil)
+			if err != nil {
+				return &retryError{fmt.Errorf("couldn't create ts request: %w", err)}
+			}
+			if r.byteRange != nil {
+				req.Header.Set("Range", r.byteRange.header())
+			}
+
+			res, err := c.httpClient().Do(req)
+			if err != nil {
+				return &retryError{fmt.Errorf("couldn't get ts: %w", err)}
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode >= 500 {
+				return &retryError{fmt.Errorf("got http status %s", res.Status)}
+			}
+
+			if res.StatusCode < 200 || res.StatusCode >= 300 {
+				return &skipError{fmt.Errorf("got non-2xx http status %s", res.Status)}
+			}
+
+			var body io.Reader = &readerError{res.Body}
+			if r.byteRange != nil {
+				body, err = rangeBody(body, res.StatusCode, *r.byteRange)
+				if err != nil {
+					return &skipError{err}
+				}
+			}
+
+			_, err = io.Copy(&writerError{out}, body)
+			if err != nil && !errors.Is(err, io.EOF) {
+				if wErr, ok := err.(*writeError); ok {
+					return &fatalError{fmt.Errorf("error while writing ts to output: %w", wErr.Unwrap())}
+				}
+
+				return &skipError{fmt.Errorf("couldn't copy ts to output: %w", err)}
+			}
+
+			return nil
+		}()
+		if err != nil {
+			if _, ok := err.(*retryError); !ok {
+				return err
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			delay := c.Retry.delay(attempt)
+			if c.Retry.exhausted(attempt, r.edge, delay) {
+				return &skipError{fmt.Errorf("giving up after %d attempt(s) in %v: %w", attempt, time.Since(start).Round(time.Millisecond), err.(*retryError).Unwrap())}
+			}
+
+			c.logf("%v\n", err)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+
+		return nil
+	}
+}
+
+// rangeBody returns the part of body given by r. Servers that ignore the
+// Range header respond with the whole resource, which is cut down here.
+func rangeBody(body io.Reader, status int, r ByteRange) (io.Reader, error) {
+	if status != http.StatusPartialContent {
+		if _, err := io.CopyN(io.Discard, body, r.Offset); err != nil {
+			return nil, fmt.Errorf("couldn't skip to byte range: %w", err)
+		}
+	}
+	return io.LimitReader(body, r.Length), nil
+}
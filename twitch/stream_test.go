@@ -0,0 +1,284 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamTs(t *testing.T) {
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, "https://example.invalid/123.ts", req.URL.String())
+		return &http.Response{
+			StatusCode: 200,
+			Body: &ReadCloserMock{
+				ReadCloser: io.NopCloser(bytes.NewBufferString("CONTENTS")),
+				CloserFunc: func() error {
+					return nil
+				},
+			},
+			Header: make(http.Header),
+		}
+	})}
+
+	ts := make(chan Segment)
+	done := make(chan error)
+	var out bytes.Buffer
+	go func() {
+		done <- client.Stream(context.Background(), ts, &out)
+	}()
+	ts <- Segment{URI: "https://example.invalid/123.ts"}
+	close(ts)
+	err := <-done
+	equals(t, nil, err)
+	equals(t, "CONTENTS", out.String())
+}
+
+func TestStreamCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var requests int
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		requests++
+		cancel()
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString("CONTENTS")),
+			Header:     make(http.Header),
+		}
+	})}
+
+	ts := make(chan Segment, 2)
+	ts <- Segment{URI: "https://example.invalid/123.ts"}
+	ts <- Segment{URI: "https://example.invalid/456.ts"}
+	close(ts)
+
+	var out bytes.Buffer
+	err := client.Stream(ctx, ts, &out)
+	equals(t, context.Canceled, err)
+	equals(t, 1, requests)
+	equals(t, "CONTENTS", out.String())
+}
+
+func TestStreamConcurrent(t *testing.T) {
+	client := &Client{
+		Concurrency: 4,
+		HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+			// Later segments finish first.
+			n, _ := strconv.Atoi(strings.TrimSuffix(path.Base(req.URL.Path), ".ts"))
+			time.Sleep(time.Millisecond * time.Duration(10*(5-n)))
+			if n == 3 {
+				return &http.Response{
+					StatusCode: 404,
+					Status:     "404 Not Found",
+					Body:       io.NopCloser(bytes.NewBufferString("")),
+					Header:     make(http.Header),
+				}
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(bytes.NewBufferString(path.Base(req.URL.Path))),
+				Header:     make(http.Header),
+			}
+		}),
+	}
+
+	ts := make(chan Segment, 5)
+	for i := 0; i < 5; i++ {
+		ts <- Segment{
+			URI:           fmt.Sprintf("https://example.invalid/%d.ts", i),
+			MapURI:        "https://example.invalid/0.mp4",
+			Discontinuity: i == 4,
+		}
+	}
+	close(ts)
+
+	var out bytes.Buffer
+	ok(t, client.Stream(context.Background(), ts, &out))
+	equals(t, "0.mp40.ts1.ts2.ts0.mp44.ts", out.String())
+}
+
+func TestStreamInitSkipped(t *testing.T) {
+	tests := []struct {
+		name string
+		// fail is the path that fails the first time it is requested.
+		fail string
+	}{
+		{"segment", "/0.mp4"},
+		{"init", "/init.mp4"},
+	}
+
+	for _, test := range tests {
+		for _, concurrency := range []int{1, 3} {
+			var mu sync.Mutex
+			failed := false
+			client := &Client{
+				Concurrency: concurrency,
+				HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+					mu.Lock()
+					defer mu.Unlock()
+					if req.URL.Path == test.fail && !failed {
+						failed = true
+						return &http.Response{
+							StatusCode: 404,
+							Status:     "404 Not Found",
+							Body:       io.NopCloser(bytes.NewBufferString("")),
+							Header:     make(http.Header),
+						}
+					}
+					return &http.Response{
+						StatusCode: 200,
+						Body:       io.NopCloser(bytes.NewBufferString(req.URL.Path + ";")),
+						Header:     make(http.Header),
+					}
+				}),
+			}
+
+			ts := make(chan Segment, 2)
+			for i := 0; i < 2; i++ {
+				ts <- Segment{
+					URI:    fmt.Sprintf("https://example.invalid/%d.mp4", i),
+					MapURI: "https://example.invalid/init.mp4",
+					Seq:    i,
+				}
+			}
+			close(ts)
+
+			var out bytes.Buffer
+			ok(t, client.Stream(context.Background(), ts, &out))
+			if out.String() != "/init.mp4;/1.mp4;" {
+				t.Errorf("%s skipped with concurrency %d: got %q", test.name, concurrency, out.String())
+			}
+		}
+	}
+}
+
+type splitWriterMock struct {
+	bytes.Buffer
+	split map[int]bool
+}
+
+func (w *splitWriterMock) BeginSegment(segment Segment) (bool, error) {
+	if w.split[segment.Seq] {
+		w.WriteString("|")
+		return true, nil
+	}
+	return false, nil
+}
+
+func (w *splitWriterMock) EndSegment(segment Segment) error {
+	w.WriteString(".")
+	return nil
+}
+
+func TestStreamSegmentWriter(t *testing.T) {
+	for _, concurrency := range []int{1, 3} {
+		client := &Client{
+			Concurrency: concurrency,
+			HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+				return &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewBufferString(path.Base(req.URL.Path))),
+					Header:     make(http.Header),
+				}
+			}),
+		}
+
+		ts := make(chan Segment, 5)
+		for i := 0; i < 5; i++ {
+			ts <- Segment{
+				URI:           fmt.Sprintf("https://example.invalid/%d.ts", i),
+				MapURI:        "https://example.invalid/0.mp4",
+				Seq:           i,
+				Discontinuity: i == 4,
+			}
+		}
+		close(ts)
+
+		out := &splitWriterMock{split: map[int]bool{2: true, 4: true}}
+		ok(t, client.Stream(context.Background(), ts, out))
+		equals(t, "0.mp40.ts.1.ts.|0.mp42.ts.3.ts.|0.mp44.ts.", out.String())
+	}
+}
+
+type initWriterMock struct {
+	bytes.Buffer
+}
+
+func (w *initWriterMock) WriteInit(p []byte) (int, error) {
+	w.WriteString("<")
+	w.Write(p)
+	w.WriteString(">")
+	return len(p), nil
+}
+
+func TestStreamInitWriter(t *testing.T) {
+	for _, concurrency := range []int{1, 3} {
+		client := &Client{
+			Concurrency: concurrency,
+			HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+				return &http.Response{
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewBufferString(path.Base(req.URL.Path))),
+					Header:     make(http.Header),
+				}
+			}),
+		}
+
+		ts := make(chan Segment, 3)
+		for i := 0; i < 3; i++ {
+			ts <- Segment{
+				URI:           fmt.Sprintf("https://example.invalid/%d.ts", i),
+				MapURI:        "https://example.invalid/0.mp4",
+				Discontinuity: i == 2,
+			}
+		}
+		close(ts)
+
+		var out initWriterMock
+		ok(t, client.Stream(context.Background(), ts, &out))
+		equals(t, "<0.mp4>0.ts1.ts<0.mp4>2.ts", out.String())
+	}
+}
+
+func TestStreamByteRange(t *testing.T) {
+	const contents = "INITFIRSTSECOND"
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		var start, end int
+		_, err := fmt.Sscanf(req.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		ok(t, err)
+
+		// The server for the second segment ignores the Range header.
+		if start == 9 {
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(contents)), Header: make(http.Header)}
+		}
+		return &http.Response{StatusCode: 206, Body: io.NopCloser(strings.NewReader(contents[start : end+1])), Header: make(http.Header)}
+	})}
+
+	key := &Key{Method: "AES-128", URI: "https://example.invalid/key"}
+	for _, concurrency := range []int{1, 2} {
+		client.Concurrency = concurrency
+
+		ts := make(chan Segment, 3)
+		segment := Segment{URI: "https://example.invalid/media.mp4", MapURI: "https://example.invalid/media.mp4", MapByteRange: &ByteRange{Length: 4}}
+		segment.ByteRange = &ByteRange{Length: 5, Offset: 4}
+		ts <- segment
+		segment.Key = key
+		ts <- segment
+		segment.ByteRange, segment.Key = &ByteRange{Length: 6, Offset: 9}, nil
+		ts <- segment
+		close(ts)
+
+		var out bytes.Buffer
+		ok(t, client.Stream(context.Background(), ts, &out))
+		equals(t, contents, out.String())
+	}
+}
@@ -0,0 +1,431 @@
+package twitch
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoLinks is returned by GetSegments when the playlist lists no segments.
+	ErrNoLinks = errors.New("no links found")
+	// ErrStreamOver is returned by GetSegments when the stream has ended.
+	ErrStreamOver = errors.New("stream over")
+	// ErrUnauthorized is returned by GetSegments when access to the playlist is denied,
+	// usually because the access token it was signed with has expired.
+	ErrUnauthorized = errors.New("playlist access denied")
+)
+
+// Segment is a single media segment of a media playlist.
+type Segment struct {
+	// Name is the title of the segment, such as "live".
+	Name string
+	URI  string
+	// ByteRange is the part of URI holding the segment, or nil if it is all of it.
+	ByteRange *ByteRange
+	// MapURI is the initialization section of the segment, if it has one.
+	MapURI string
+	// MapByteRange is the part of MapURI holding the initialization section,
+	// or nil if it is all of it.
+	MapByteRange *ByteRange
+	// Key is the encryption of the segment, or nil if it is not encrypted.
+	Key *Key
+	// Duration is the duration of the segment in seconds.
+	Duration float64
+	Seq      int
+	// DiscontinuitySeq is the discontinuity sequence number of the segment.
+	DiscontinuitySeq int
+	Discontinuity    bool
+	Prefetch         bool
+	// ProgramDateTime is the wall clock time of the first sample of the segment, if known.
+	ProgramDateTime time.Time
+	// DateRanges are the date ranges the segment falls within.
+	DateRanges []DateRange
+	// Ad reports whether the segment belongs to an advertisement stitched into the stream.
+	Ad bool
+	// EdgeTime is when the segment was the newest in the playlist, estimated
+	// by Poller from the segments after it when it was first seen. It is the
+	// zero time for playlists that have ended, which have no live edge.
+	EdgeTime time.Time
+}
+
+// DateRange is an EXT-X-DATERANGE tag of a media playlist.
+type DateRange struct {
+	ID        string
+	Class     string
+	StartDate time.Time
+	EndDate   time.Time
+	Duration  time.Duration
+	EndOnNext bool
+	// Extra holds the client defined X- attributes as KEY=VALUE pairs.
+	Extra []string
+}
+
+func parseDateRange(s string) (DateRange, error) {
+	var dr DateRange
+	for _, attr := range parseAttributes(s) {
+		var err error
+		switch attr.Key {
+		case "ID":
+			dr.ID = attr.Value
+		case "CLASS":
+			dr.Class = attr.Value
+		case "START-DATE":
+			dr.StartDate, err = time.Parse(time.RFC3339Nano, attr.Value)
+		case "END-DATE":
+			dr.EndDate, err = time.Parse(time.RFC3339Nano, attr.Value)
+		case "DURATION":
+			var d float64
+			d, err = strconv.ParseFloat(attr.Value, 64)
+			dr.Duration = time.Duration(d * float64(time.Second))
+		case "END-ON-NEXT":
+			dr.EndOnNext = attr.Value == "YES"
+		default:
+			if strings.HasPrefix(attr.Key, "X-") {
+				dr.Extra = append(dr.Extra, attr.Key+"="+attr.Value)
+			}
+		}
+		if err != nil {
+			return dr, fmt.Errorf("invalid %s: %w", attr.Key, err)
+		}
+	}
+
+	if dr.ID == "" || dr.StartDate.IsZero() {
+		return dr, errors.New("missing ID or START-DATE")
+	}
+
+	return dr, nil
+}
+
+// end returns the time the date range ends, or the zero time if it is unknown.
+// ranges are the other date ranges of the playlist, used to resolve END-ON-NEXT.
+func (dr DateRange) end(ranges []DateRange) time.Time {
+	switch {
+	case !dr.EndDate.IsZero():
+		return dr.EndDate
+	case dr.Duration > 0:
+		return dr.StartDate.Add(dr.Duration)
+	case dr.EndOnNext:
+		var next time.Time
+		for _, r := range ranges {
+			if r.Class == dr.Class && r.StartDate.After(dr.StartDate) && (next.IsZero() || r.StartDate.Before(next)) {
+				next = r.StartDate
+			}
+		}
+		return next
+	}
+	return time.Time{}
+}
+
+// contains reports whether t falls within the date range.
+func (dr DateRange) contains(t time.Time, ranges []DateRange) bool {
+	end := dr.end(ranges)
+	return !t.Before(dr.StartDate) && !end.IsZero() && t.Before(end)
+}
+
+// isAd reports whether segment is part of a stitched advertisement,
+// either because it is covered by an ad date range or because of its title.
+func (s *Segment) isAd() bool {
+	for _, dr := range s.DateRanges {
+		if dr.Class == stitchedAdClass {
+			return true
+		}
+	}
+	return strings.Contains(s.Name, "Amazon")
+}
+
+// resolveURI resolves uri against the URL of the playlist it appeared in,
+// returning it unchanged if it cannot be parsed.
+func resolveURI(base *url.URL, uri string) string {
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// ByteRange is a sub-range of a resource, as given by EXT-X-BYTERANGE or the
+// BYTERANGE attribute of EXT-X-MAP.
+type ByteRange struct {
+	Length int64
+	Offset int64
+}
+
+// header returns the value of the Range header requesting r.
+func (r ByteRange) header() string {
+	return fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1)
+}
+
+// parseByteRange parses a byte range of the form <n>[@<o>]. If no offset is
+// given, the range starts at next.
+func parseByteRange(s string, next int64) (*ByteRange, error) {
+	length, offset, found := strings.Cut(s, "@")
+	r := &ByteRange{Offset: next}
+
+	var err error
+	if r.Length, err = strconv.ParseInt(length, 10, 64); err != nil || r.Length <= 0 {
+		return nil, fmt.Errorf("invalid byte range %q", s)
+	}
+	if found {
+		if r.Offset, err = strconv.ParseInt(offset, 10, 64); err != nil || r.Offset < 0 {
+			return nil, fmt.Errorf("invalid byte range %q", s)
+		}
+	}
+
+	return r, nil
+}
+
+// Key is an EXT-X-KEY tag of a media playlist, describing how the segments
+// after it are encrypted.
+type Key struct {
+	// Method is the encryption method, such as "AES-128".
+	Method string
+	// URI is the key, resolved against the URL of the playlist.
+	URI string
+	// IV is the hexadecimal initialization vector, if given.
+	IV                string
+	KeyFormat         string
+	KeyFormatVersions string
+}
+
+// MediaPlaylist is a media playlist as described in RFC 8216 section 4.3.3.
+type MediaPlaylist struct {
+	Version int
+	// TargetDuration is the maximum segment duration in seconds, or 0 if unknown.
+	TargetDuration float64
+	// MediaSequence is the media sequence number of the first segment.
+	MediaSequence int
+	// DiscontinuitySequence is the discontinuity sequence number of the first segment.
+	DiscontinuitySequence int
+	// PlaylistType is "EVENT" or "VOD", or "" if the playlist may change in any way.
+	PlaylistType        string
+	IndependentSegments bool
+	// EndList reports whether no more segments will be added to the playlist.
+	EndList  bool
+	Segments []Segment
+	// DateRanges are all date ranges of the playlist.
+	DateRanges []DateRange
+}
+
+// GetSegments fetches the media playlist and returns the segments it lists.
+// If the playlist signals the end of the stream, the segments are returned
+// together with ErrStreamOver.
+func (c *Client) GetSegments(ctx context.Context, playlist string) ([]Segment, error) {
+	mp, err := c.GetMediaPlaylist(ctx, playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mp.Segments) == 0 {
+		return nil, ErrNoLinks
+	}
+	if mp.EndList {
+		return mp.Segments, ErrStreamOver
+	}
+	return mp.Segments, nil
+}
+
+// GetMediaPlaylist fetches and parses the media playlist at playlist.
+// URIs in the playlist are resolved against playlist. ErrStreamOver is
+// returned if the playlist no longer exists.
+func (c *Client) GetMediaPlaylist(ctx context.Context, playlist string) (*MediaPlaylist, error) {
+	base, err := url.Parse(playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", playlist, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Origin", "https://player.twitch.tv")
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		switch res.StatusCode {
+		case http.StatusNotFound:
+			return nil, ErrStreamOver
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return nil, ErrUnauthorized
+		}
+
+		return nil, fmt.Errorf("urls got http status %s", res.Status)
+	}
+
+	return c.parseMediaPlaylist(res.Body, base)
+}
+
+// parseMediaPlaylist parses the media playlist r, resolving URIs against base.
+// Malformed tags are skipped.
+func (c *Client) parseMediaPlaylist(r io.Reader, base *url.URL) (*MediaPlaylist, error) {
+	mp := &MediaPlaylist{}
+
+	var segment Segment
+	var mapURI string
+	var mapRange *ByteRange
+	var key *Key
+	var discontinuities int
+	// rangeURI and rangeEnd are the URI and the end of the last segment
+	// with a byte range, which a byte range without an offset continues.
+	var rangeURI string
+	var rangeEnd int64
+
+	// add ends the current segment at its URI.
+	add := func(uri string) {
+		segment.URI = resolveURI(base, uri)
+		segment.Seq = mp.MediaSequence + len(mp.Segments)
+		segment.DiscontinuitySeq = mp.DiscontinuitySequence + discontinuities
+		segment.MapURI, segment.MapByteRange, segment.Key = mapURI, mapRange, key
+
+		if r := segment.ByteRange; r != nil {
+			if r.Offset < 0 {
+				r.Offset = 0
+				if rangeURI == segment.URI {
+					r.Offset = rangeEnd
+				}
+			}
+			rangeURI, rangeEnd = segment.URI, r.Offset+r.Length
+		}
+
+		mp.Segments = append(mp.Segments, segment)
+		segment = Segment{}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "#") {
+			add(line)
+			continue
+		}
+
+		tag, value, _ := strings.Cut(line, ":")
+		switch tag {
+		case versionTag:
+			if v, err := strconv.Atoi(value); err == nil {
+				mp.Version = v
+			}
+		case targetDurationTag:
+			if td, err := strconv.ParseFloat(value, 64); err == nil {
+				mp.TargetDuration = td
+			}
+		case mediaSequenceTag:
+			if seq, err := strconv.Atoi(value); err == nil {
+				mp.MediaSequence = seq
+			}
+		case discontinuitySequenceTag:
+			if seq, err := strconv.Atoi(value); err == nil {
+				mp.DiscontinuitySequence = seq
+			}
+		case playlistTypeTag:
+			mp.PlaylistType = value
+		case independentSegmentsTag:
+			mp.IndependentSegments = true
+		case endListTag:
+			mp.EndList = true
+		case mapTag:
+			attrs := parseAttributes(value)
+			uri, found := attrs.Get("URI")
+			if !found {
+				break
+			}
+			mapURI, mapRange = resolveURI(base, uri), nil
+			if v, found := attrs.Get("BYTERANGE"); found {
+				if r, err := parseByteRange(v, 0); err == nil {
+					mapRange = r
+				}
+			}
+		case keyTag:
+			attrs := parseAttributes(value)
+			k := &Key{}
+			k.Method, _ = attrs.Get("METHOD")
+			if k.Method == "" {
+				break
+			}
+			if k.Method == "NONE" {
+				key = nil
+				break
+			}
+			if uri, found := attrs.Get("URI"); found {
+				k.URI = resolveURI(base, uri)
+			}
+			k.IV, _ = attrs.Get("IV")
+			k.KeyFormat, _ = attrs.Get("KEYFORMAT")
+			k.KeyFormatVersions, _ = attrs.Get("KEYFORMATVERSIONS")
+			key = k
+		case infTag:
+			// The title is kept even if the duration is malformed,
+			// as ads are also detected by it.
+			durationText, title, _ := strings.Cut(value, ",")
+			segment.Name = title
+			if duration, err := strconv.ParseFloat(durationText, 64); err == nil {
+				segment.Duration = duration
+			}
+		case byteRangeTag:
+			// The offset is filled in once the URI of the segment is known.
+			if r, err := parseByteRange(value, -1); err == nil {
+				segment.ByteRange = r
+			}
+		case programDateTimeTag:
+			pdt, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				break
+			}
+			segment.ProgramDateTime = pdt
+		case dateRangeTag:
+			dr, err := parseDateRange(value)
+			if err != nil {
+				c.logf("ignoring invalid date range: %v\n", err)
+				break
+			}
+			mp.DateRanges = append(mp.DateRanges, dr)
+		case discontinuityTag:
+			segment.Discontinuity = true
+			discontinuities++
+		case prefetchTag:
+			segment.Prefetch = true
+			add(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range mp.Segments {
+		s := &mp.Segments[i]
+		if !s.ProgramDateTime.IsZero() {
+			for _, dr := range mp.DateRanges {
+				if dr.contains(s.ProgramDateTime, mp.DateRanges) {
+					s.DateRanges = append(s.DateRanges, dr)
+				}
+			}
+		}
+		s.Ad = s.isAd()
+
+		// Prefetch segments carry no metadata of their own,
+		// assume they continue whatever came before them.
+		if s.Prefetch && i > 0 {
+			s.Ad = mp.Segments[i-1].Ad
+		}
+	}
+
+	return mp, nil
+}
@@ -0,0 +1,185 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestGetSegments(t *testing.T) {
+	prefetchURL := "https://example.invalid/123.ts"
+	initURL := "https://example.invalid/init.mp4"
+	normalURL := "https://example.invalid/456.ts"
+
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		equals(t, "https://example.invalid/123.m3u8", req.URL.String())
+		return &http.Response{
+			StatusCode: 200,
+			Body: io.NopCloser(bytes.NewBufferString(
+				fmt.Sprintf("#EXTM3U\n#EXT-X-MAP:URI=\"%s\"\n#EXTINF:2.00,live\n%s\n#EXT-X-TWITCH-PREFETCH:%s\n", initURL, normalURL, prefetchURL),
+			)),
+			Header: make(http.Header),
+		}
+	})}
+
+	urls, err := client.GetSegments(context.Background(), "https://example.invalid/123.m3u8")
+	ok(t, err)
+
+	equals(t, 2, len(urls))
+	equals(t, Segment{
+		Name:          "live",
+		URI:           normalURL,
+		MapURI:        initURL,
+		Duration:      2,
+		Seq:           0,
+		Discontinuity: false,
+		Prefetch:      false,
+	}, urls[0])
+	equals(t, Segment{
+		Name:          "",
+		URI:           prefetchURL,
+		MapURI:        initURL,
+		Duration:      0,
+		Seq:           1,
+		Discontinuity: false,
+		Prefetch:      true,
+	}, urls[1])
+}
+
+func TestGetSegmentsAds(t *testing.T) {
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body: io.NopCloser(bytes.NewBufferString(`#EXTM3U
+#EXT-X-MEDIA-SEQUENCE:1
+#EXT-X-DATERANGE:ID="stitched-ad-1",CLASS="twitch-stitched-ad",START-DATE="2022-01-01T00:00:02.000Z",DURATION=4.000,X-TV-TWITCH-AD-ROLL-TYPE="MIDROLL",X-TV-TWITCH-AD-POD-LENGTH="2"
+#EXT-X-PROGRAM-DATE-TIME:2022-01-01T00:00:00.000Z
+#EXTINF:2.000,live
+https://example.invalid/1.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2022-01-01T00:00:02.000Z
+#EXTINF:2.000,Amazon|123
+https://example.invalid/2.ts
+#EXT-X-PROGRAM-DATE-TIME:2022-01-01T00:00:04.000Z
+#EXTINF:2.000,
+https://example.invalid/3.ts
+#EXT-X-TWITCH-PREFETCH:https://example.invalid/4.ts
+#EXT-X-DISCONTINUITY
+#EXT-X-PROGRAM-DATE-TIME:2022-01-01T00:00:06.000Z
+#EXTINF:2.000,live
+https://example.invalid/5.ts
+`)),
+			Header: make(http.Header),
+		}
+	})}
+
+	urls, err := client.GetSegments(context.Background(), "https://example.invalid/123.m3u8")
+	ok(t, err)
+
+	var ads []bool
+	var duration float64
+	for _, u := range urls {
+		ads = append(ads, u.Ad)
+		duration += u.Duration
+	}
+	equals(t, []bool{false, true, true, true, false}, ads)
+	equals(t, 8.0, duration)
+	equals(t, "Amazon|123", urls[1].Name)
+
+	equals(t, 1, len(urls[2].DateRanges))
+	dr := urls[2].DateRanges[0]
+	equals(t, "stitched-ad-1", dr.ID)
+	equals(t, time.Second*4, dr.Duration)
+	equals(t, []string{"X-TV-TWITCH-AD-ROLL-TYPE=MIDROLL", "X-TV-TWITCH-AD-POD-LENGTH=2"}, dr.Extra)
+}
+
+func TestGetSegmentsRelative(t *testing.T) {
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body: io.NopCloser(bytes.NewBufferString(`#EXTM3U
+#EXT-X-MAP:URI="init-0.mp4"
+#EXTINF:10.000,
+0.ts
+#EXTINF:10.000,
+1-muted.ts
+#EXT-X-ENDLIST
+`)),
+			Header: make(http.Header),
+		}
+	})}
+
+	urls, err := client.GetSegments(context.Background(), "https://example.invalid/abc/chunked/index-dvr.m3u8")
+	equals(t, ErrStreamOver, err)
+
+	equals(t, 2, len(urls))
+	equals(t, "https://example.invalid/abc/chunked/0.ts", urls[0].URI)
+	equals(t, "https://example.invalid/abc/chunked/1-muted.ts", urls[1].URI)
+	equals(t, "https://example.invalid/abc/chunked/init-0.mp4", urls[1].MapURI)
+}
+
+// TestParseMediaPlaylist parses every playlist in testdata and compares the
+// result, encoded as JSON, with the golden file of the same name.
+func TestParseMediaPlaylist(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.m3u8")
+	ok(t, err)
+	assert(t, len(files) > 0, "no playlists in testdata")
+
+	base, err := url.Parse("https://example.invalid/abc/chunked/index.m3u8")
+	ok(t, err)
+
+	client := &Client{}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			f, err := os.Open(file)
+			ok(t, err)
+			defer f.Close()
+
+			mp, err := client.parseMediaPlaylist(f, base)
+			ok(t, err)
+
+			got, err := json.MarshalIndent(mp, "", "\t")
+			ok(t, err)
+			got = append(got, '\n')
+
+			golden := strings.TrimSuffix(file, ".m3u8") + ".golden"
+			if *update {
+				ok(t, os.WriteFile(golden, got, 0644))
+			}
+
+			exp, err := os.ReadFile(golden)
+			ok(t, err)
+			equals(t, string(exp), string(got))
+		})
+	}
+}
+
+func TestGetMediaPlaylistEndList(t *testing.T) {
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString("#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-ENDLIST\n")),
+			Header:     make(http.Header),
+		}
+	})}
+
+	mp, err := client.GetMediaPlaylist(context.Background(), "https://example.invalid/123.m3u8")
+	ok(t, err)
+	equals(t, true, mp.EndList)
+	equals(t, 0, len(mp.Segments))
+
+	_, err = client.GetSegments(context.Background(), "https://example.invalid/123.m3u8")
+	equals(t, ErrNoLinks, err)
+}
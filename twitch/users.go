@@ -0,0 +1,84 @@
+package twitch
+
+import (
+	"context"
+	_ "embed"
+	"time"
+)
+
+//go:embed users.gql
+var usersQuery string
+
+// User is a Twitch user and their current stream, if they are live.
+type User struct {
+	ID          string
+	Login       string
+	DisplayName string
+	// Title is the title of the channel's current or most recent broadcast.
+	Title string
+	// Stream is nil if the user is not live.
+	Stream *Stream
+}
+
+// Stream is a live broadcast.
+type Stream struct {
+	// ID is the broadcast ID.
+	ID        string
+	Game      string
+	CreatedAt time.Time
+}
+
+type gqlUsers struct {
+	Users []*struct {
+		ID                string `json:"id"`
+		Login             string `json:"login"`
+		DisplayName       string `json:"displayName"`
+		BroadcastSettings *struct {
+			Title string `json:"title"`
+		} `json:"broadcastSettings"`
+		Stream *struct {
+			ID        string    `json:"id"`
+			CreatedAt time.Time `json:"createdAt"`
+			Game      *struct {
+				Name string `json:"name"`
+			} `json:"game"`
+		} `json:"stream"`
+	} `json:"users"`
+}
+
+// GetUsers looks up the users with the given logins. The result has one entry
+// per login, in the same order, which is nil if the user does not exist.
+func (c *Client) GetUsers(ctx context.Context, logins []string) ([]*User, error) {
+	var res gqlUsers
+	if err := c.gql(ctx, usersQuery, map[string]any{"logins": logins}, &res, "users"); err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, len(logins))
+	for i, u := range res.Users {
+		if i >= len(users) || u == nil {
+			continue
+		}
+
+		user := &User{
+			ID:          u.ID,
+			Login:       u.Login,
+			DisplayName: u.DisplayName,
+		}
+		if u.BroadcastSettings != nil {
+			user.Title = u.BroadcastSettings.Title
+		}
+		if u.Stream != nil {
+			user.Stream = &Stream{
+				ID:        u.Stream.ID,
+				CreatedAt: u.Stream.CreatedAt,
+			}
+			if u.Stream.Game != nil {
+				user.Stream.Game = u.Stream.Game.Name
+			}
+		}
+		users[i] = user
+	}
+
+	return users, nil
+}
@@ -0,0 +1,52 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetUsers(t *testing.T) {
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		var gqlq gqlQuery
+		ok(t, json.NewDecoder(req.Body).Decode(&gqlq))
+		equals(t, []any{"live", "missing", "offline"}, gqlq.Variables["logins"])
+
+		return &http.Response{
+			StatusCode: 200,
+			Body: io.NopCloser(bytes.NewBufferString(`{"data":{"users":[
+{"id":"1","login":"live","displayName":"Live","broadcastSettings":{"title":"hello"},"stream":{"id":"99","createdAt":"2022-01-01T00:00:00Z","game":{"name":"Chess"}}},
+null,
+{"id":"3","login":"offline","displayName":"Offline","broadcastSettings":{"title":""},"stream":null}
+]}}`)),
+			Header: make(http.Header),
+		}
+	})}
+
+	users, err := client.GetUsers(context.Background(), []string{"live", "missing", "offline"})
+	ok(t, err)
+
+	equals(t, []*User{
+		{
+			ID:          "1",
+			Login:       "live",
+			DisplayName: "Live",
+			Title:       "hello",
+			Stream: &Stream{
+				ID:        "99",
+				Game:      "Chess",
+				CreatedAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		nil,
+		{
+			ID:          "3",
+			Login:       "offline",
+			DisplayName: "Offline",
+		},
+	}, users)
+}
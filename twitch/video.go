@@ -0,0 +1,58 @@
+package twitch
+
+import (
+	"context"
+	_ "embed"
+)
+
+//go:embed video.gql
+var videoQuery string
+
+// Video is a video (VOD).
+type Video struct {
+	ID         string
+	Title      string
+	Game       string
+	OwnerID    string
+	OwnerLogin string
+}
+
+type gqlVideo struct {
+	Video *struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+		Owner *struct {
+			ID    string `json:"id"`
+			Login string `json:"login"`
+		} `json:"owner"`
+		Game *struct {
+			Name string `json:"name"`
+		} `json:"game"`
+	} `json:"video"`
+}
+
+// GetVideo fetches the details of the video with the given ID.
+func (c *Client) GetVideo(ctx context.Context, id string) (*Video, error) {
+	var res gqlVideo
+	if err := c.gql(ctx, videoQuery, map[string]any{"id": id}, &res, "video"); err != nil {
+		return nil, err
+	}
+
+	if res.Video == nil {
+		return nil, ErrVideoNotFound
+	}
+
+	video := &Video{
+		ID:    res.Video.ID,
+		Title: res.Video.Title,
+	}
+	if res.Video.Owner != nil {
+		video.OwnerID = res.Video.Owner.ID
+		video.OwnerLogin = res.Video.Owner.Login
+	}
+	if res.Video.Game != nil {
+		video.Game = res.Video.Game.Name
+	}
+
+	return video, nil
+}
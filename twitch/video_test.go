@@ -0,0 +1,41 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestGetVideo(t *testing.T) {
+	client := &Client{HTTPClient: NewTestClient(func(req *http.Request) *http.Response {
+		var gqlq gqlQuery
+		ok(t, json.NewDecoder(req.Body).Decode(&gqlq))
+
+		body := `{"data":{"video":null}}`
+		if gqlq.Variables["id"] == "123456" {
+			body = `{"data":{"video":{"id":"123456","title":"hello","owner":{"id":"1","login":"username"},"game":{"name":"Chess"}}}}`
+		}
+
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+			Header:     make(http.Header),
+		}
+	})}
+
+	video, err := client.GetVideo(context.Background(), "123456")
+	ok(t, err)
+	equals(t, &Video{
+		ID:         "123456",
+		Title:      "hello",
+		Game:       "Chess",
+		OwnerID:    "1",
+		OwnerLogin: "username",
+	}, video)
+
+	_, err = client.GetVideo(context.Background(), "1")
+	equals(t, ErrVideoNotFound, err)
+}
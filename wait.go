@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+// waitForPlaylists returns the playlists of t. If wait is set, it keeps
+// checking t until it goes live, doubling the interval between checks from
+// waitInterval up to waitMaxInterval, and only returns an error once ctx is
+// cancelled.
+func waitForPlaylists(ctx context.Context, client *twitch.Client, t target, wait bool) ([]twitch.PlaylistInfo, error) {
+	return waitFor(ctx, t, wait, func(ctx context.Context) ([]twitch.PlaylistInfo, error) {
+		return t.playlists(ctx, client)
+	})
+}
+
+// waitFor is waitForPlaylists with the playlists of t fetched by check.
+func waitFor(ctx context.Context, t target, wait bool, check func(context.Context) ([]twitch.PlaylistInfo, error)) ([]twitch.PlaylistInfo, error) {
+	interval := waitInterval
+	maxInterval := waitMaxInterval
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+
+	var announced bool
+	for {
+		playlists, err := check(ctx)
+		if err == nil || !wait || ctx.Err() != nil {
+			return playlists, err
+		}
+
+		if errors.Is(err, twitch.ErrOffline) {
+			if !announced {
+				stdErr.Printf("%s is offline, waiting for stream to start...\n", t)
+				announced = true
+			}
+		} else {
+			stdErr.Printf("%v, retrying in %v\n", err, interval)
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Hakkin/twitchpipe/twitch"
+)
+
+func TestWaitFor(t *testing.T) {
+	var logged bytes.Buffer
+	stdErr.SetOutput(&logged)
+	defer stdErr.SetOutput(os.Stderr)
+
+	waitInterval, waitMaxInterval = time.Millisecond, time.Millisecond*4
+	defer func() {
+		waitInterval, waitMaxInterval = waitIntervalDefault, waitMaxIntervalDefault
+	}()
+
+	channel := target{channelTarget, "channel"}
+	live := []twitch.PlaylistInfo{{Group: "chunked"}}
+	failing := errors.New("failing")
+
+	// check returns errs in turn, then the playlists.
+	check := func(errs ...error) func(context.Context) ([]twitch.PlaylistInfo, error) {
+		return func(ctx context.Context) ([]twitch.PlaylistInfo, error) {
+			if len(errs) == 0 {
+				return live, nil
+			}
+			err := errs[0]
+			errs = errs[1:]
+			return nil, err
+		}
+	}
+
+	tests := []struct {
+		wait bool
+		errs []error
+		exp  string
+		err  error
+	}{
+		{true, []error{twitch.ErrOffline, twitch.ErrOffline, twitch.ErrOffline}, "channel is offline, waiting for stream to start...\n", nil},
+		{true, []error{failing, failing, failing, failing, failing}, "failing, retrying in 1ms\nfailing, retrying in 2ms\nfailing, retrying in 4ms\nfailing, retrying in 4ms\nfailing, retrying in 4ms\n", nil},
+		{false, []error{twitch.ErrOffline}, "", twitch.ErrOffline},
+	}
+
+	for _, test := range tests {
+		logged.Reset()
+		playlists, err := waitFor(context.Background(), channel, test.wait, check(test.errs...))
+		if err != test.err {
+			t.Errorf("waitFor(%v) returned %v, expected %v", test.errs, err, test.err)
+		}
+		if err == nil && fmt.Sprint(playlists) != fmt.Sprint(live) {
+			t.Errorf("waitFor(%v) returned %v, expected %v", test.errs, playlists, live)
+		}
+		if got := logged.String(); got != test.exp {
+			t.Errorf("waitFor(%v) logged %q, expected %q", test.errs, got, test.exp)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := waitFor(ctx, channel, true, check(failing)); err != failing {
+		t.Errorf("waitFor with a cancelled context returned %v, expected %v", err, failing)
+	}
+}